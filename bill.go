@@ -0,0 +1,754 @@
+package main
+
+import (
+	"context"
+	"electricity-invoice-calculator/lib/billing"
+	"electricity-invoice-calculator/lib/eloverblik"
+	"electricity-invoice-calculator/lib/energinet"
+	"electricity-invoice-calculator/lib/utils"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// options holds the command line flags. Choices left empty are asked for interactively,
+// unless nonInteractive is set, in which case a missing required choice is an error.
+type options struct {
+	meterPoint        string
+	calculationType   string
+	frequency         string
+	period            string
+	spotMethod        string
+	consumption       billing.ConsumptionEstimator
+	fixedSpotPrice    float64
+	fixedSpotPriceSet bool
+	nonInteractive    bool
+	output            string
+	csvFile           string
+	tariffHistory     bool
+	resolution        string
+	aggregation       string // Eloverblik aggregation matching resolution
+	consumptionFile   string
+	chargesFile       string
+	priceArea         string
+	gridOperator      string
+	forwardCurve      string
+	missingData       billing.CompletenessMode
+	common            *commonOptions
+}
+
+// parseBillFlags reads the bill command line flags into options
+func parseBillFlags(args []string) options {
+	var opts options
+
+	flags := flag.NewFlagSet("bill", flag.ExitOnError)
+	flags.StringVar(&opts.meterPoint, "meter-point", "", "meter point ID to calculate the bill for")
+	flags.StringVar(&opts.calculationType, "type", "", "calculation type: historical or aconto")
+	flags.StringVar(&opts.frequency, "frequency", "", "billing frequency: monthly or quarterly")
+	flags.StringVar(&opts.period, "period", "", "billing period, e.g. 2025-07 (monthly) or 2025-Q3 (quarterly)")
+	flags.StringVar(&opts.spotMethod, "spot-method", "", "aconto spot price estimator: "+strings.Join(billing.SpotPriceEstimatorNames(), ", ")+" (fixed 0.614 DKK/kWh or historical prices from last year)")
+	consumptionMethod := flags.String("consumption-method", "flat", "aconto consumption estimator: "+strings.Join(billing.ConsumptionEstimatorNames(), ", ")+" (flat spreads the annual volume evenly, profile follows a seasonal residential load profile, history repeats the meter point's last 12-24 months)")
+	flags.Float64Var(&opts.fixedSpotPrice, "fixed-spot-price", 0, "fixed spot price in DKK/kWh for the estimated part of a hybrid period, unless --spot-method selects another estimator")
+	flags.BoolVar(&opts.nonInteractive, "non-interactive", false, "never prompt; fail if a required choice is missing")
+	flags.StringVar(&opts.output, "output", "text", "output format of the bill: text or json")
+	flags.StringVar(&opts.csvFile, "csv", "", "write the hourly cost breakdown to this CSV file")
+	flags.BoolVar(&opts.tariffHistory, "tariff-history", true, "price past hours with the tariffs valid at the time (from DatahubPricelist)")
+	flags.StringVar(&opts.resolution, "resolution", "hour", "resolution of the metered consumption: hour, quarter or actual (as settled by the meter)")
+	flags.StringVar(&opts.consumptionFile, "consumption-file", "", "read actual consumption from a Måledata export (CSV or .xlsx) from the Eloverblik website instead of the API; works without an Eloverblik token")
+	flags.StringVar(&opts.chargesFile, "charges-file", "", "read tariffs and subscriptions from a file saved from the charges command instead of Eloverblik")
+	flags.StringVar(&opts.priceArea, "price-area", "", "price area DK1 or DK2 (default: from the grid operator)")
+	flags.StringVar(&opts.gridOperator, "grid-operator", "", "grid operator name, used with --consumption-file when the meter point details are not cached")
+	flags.StringVar(&opts.forwardCurve, "forward-curve", "", "forward price curve (CSV or JSON with monthly or quarterly DK1/DK2 prices); adds the spot price estimator \"forward\"")
+	missingData := flags.String("missing-data", "strict", "missing or duplicated hours: strict aborts, lenient interpolates and flags them")
+	opts.common = addCommonFlags(flags)
+	flags.Parse(args)
+	opts.common.apply()
+
+	mode, err := billing.ParseCompletenessMode(*missingData)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts.missingData = mode
+
+	if opts.consumption, err = billing.LookupConsumptionEstimator(*consumptionMethod); err != nil {
+		log.Fatal(err)
+	}
+
+	if opts.forwardCurve != "" {
+		curve, err := billing.LoadForwardCurve(opts.forwardCurve)
+		if err != nil {
+			log.Fatal(err)
+		}
+		billing.RegisterSpotPriceEstimator(billing.ForwardCurveSpotPrices{Curve: curve})
+	} else if strings.EqualFold(opts.spotMethod, "forward") {
+		log.Fatal("--spot-method forward needs a price curve from --forward-curve")
+	}
+
+	if opts.priceArea != "" && opts.priceArea != "DK1" && opts.priceArea != "DK2" {
+		log.Fatalf("invalid --price-area %q (expected DK1 or DK2)", opts.priceArea)
+	}
+
+	if opts.output != "text" && opts.output != "json" {
+		log.Fatalf("invalid output format %q (expected text or json)", opts.output)
+	}
+	opts.aggregation = parseAggregation(opts.resolution)
+
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "fixed-spot-price" {
+			opts.fixedSpotPriceSet = true
+		}
+	})
+
+	return opts
+}
+
+// require stops the program when a choice is missing and prompting is not allowed
+func (opts options) require(flagName, value string) {
+	if value == "" && opts.nonInteractive {
+		log.Fatalf("--%s is required in non-interactive mode", flagName)
+	}
+}
+
+// selectMeterPoint gets and displays meter points for user selection.
+// If --meter-point is given, that meter point is selected without prompting.
+func selectMeterPoint(ctx context.Context, client *eloverblik.Client, opts options) eloverblik.MeterPoint {
+	utils.PrintAction("Getting meter points...")
+	meterPoints, err := client.GetMeterPoints(ctx)
+	if err != nil {
+		log.Fatal("Failed to get meter points: ", err)
+	}
+
+	if len(meterPoints) == 0 {
+		log.Fatal("No meter points found.")
+	}
+
+	if opts.meterPoint != "" {
+		for _, mp := range meterPoints {
+			if mp.ID == opts.meterPoint {
+				utils.PrintSuccess(fmt.Sprintf("✓ Selected meter point: %s", mp.ID))
+				return mp
+			}
+		}
+
+		ids := make([]string, len(meterPoints))
+		for i, mp := range meterPoints {
+			ids[i] = mp.ID
+		}
+		log.Fatalf("Meter point %s not found (available: %s)", opts.meterPoint, strings.Join(ids, ", "))
+	}
+	opts.require("meter-point", opts.meterPoint)
+
+	utils.ClearConsole()
+
+	// Format options for display
+	title := fmt.Sprintf("Found %d meter point(s):", len(meterPoints))
+	formattedOptions := make([]string, len(meterPoints))
+	for i, mp := range meterPoints {
+		formattedOptions[i] = displayMeterPoint(mp, i)
+	}
+
+	selectedIndex := utils.GetUserChoice(title, formattedOptions)
+	selectedMeterPoint := meterPoints[selectedIndex]
+
+	utils.PrintSuccess(fmt.Sprintf("✓ Selected meter point: %s", selectedMeterPoint.ID))
+	return selectedMeterPoint
+}
+
+// getGridOperatorInfo fetches grid operator details for the selected meter point
+func getGridOperatorInfo(ctx context.Context, client *eloverblik.Client, meterPoint eloverblik.MeterPoint) eloverblik.MeterPointDetails {
+	utils.PrintAction("Getting detailed information...")
+	gridOperator, err := client.GetMeterPointDetails(ctx, meterPoint.ID)
+	if err != nil {
+		log.Fatal("Failed to get grid operator details:", err)
+	}
+
+	return gridOperator
+}
+
+// displayMeterPointDetails shows the selected meter point and grid operator details
+func displayMeterPointDetails(meterPoint eloverblik.MeterPoint, gridOperator eloverblik.MeterPointDetails) {
+	utils.ClearConsole()
+
+	utils.PrintInfo("Meter Point Details:")
+	fmt.Fprintf(utils.Output, "ID: %s\n", meterPoint.ID)
+	fmt.Fprintf(utils.Output, "Address: %s %s, %s %s\n",
+		meterPoint.StreetName,
+		meterPoint.BuildingNumber,
+		meterPoint.PostCode,
+		meterPoint.City)
+	fmt.Fprintf(utils.Output, "Grid Operator: %s\n", gridOperator.Name)
+	fmt.Fprintf(utils.Output, "Estimated Annual Volume: %d kWh\n", gridOperator.EstimatedAnnualVolume)
+}
+
+// selectCalculationType returns the calculation type from flags or asks the user
+func selectCalculationType(opts options) billing.CalculationType {
+	opts.require("type", opts.calculationType)
+	if opts.calculationType == "" {
+		return billing.GetCalculationType()
+	}
+
+	calculationType, err := billing.ParseCalculationType(opts.calculationType)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return calculationType
+}
+
+// selectBillingFrequency returns the billing frequency from flags or asks the user
+func selectBillingFrequency(opts options) billing.BillingFrequency {
+	opts.require("frequency", opts.frequency)
+	if opts.frequency == "" {
+		return billing.GetBillingFrequency()
+	}
+
+	frequency, err := billing.ParseBillingFrequency(opts.frequency)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return frequency
+}
+
+// selectPeriod returns the period from flags or asks the user
+func selectPeriod(periods []billing.Period, opts options) billing.Period {
+	opts.require("period", opts.period)
+	if opts.period == "" {
+		return billing.SelectPeriod(periods)
+	}
+
+	period, err := billing.FindPeriod(periods, opts.period)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return period
+}
+
+// Labels of the built-in spot price estimators in the interactive choice
+var spotPriceEstimatorLabels = map[string]string{
+	"fixed":      "Use fixed estimate (614.029 DKK/MWh)",
+	"historical": "Use historical prices from same period last year",
+	"forward":    "Use the forward price curve shaped by a daily profile",
+}
+
+// selectSpotPriceEstimator returns the estimator of aconto spot prices from flags or asks the user
+func selectSpotPriceEstimator(opts options) billing.SpotPriceEstimator {
+	opts.require("spot-method", opts.spotMethod)
+
+	name := strings.ToLower(opts.spotMethod)
+	if name == "" {
+		names := billing.SpotPriceEstimatorNames()
+		spotPriceOptions := make([]string, len(names))
+		for i, name := range names {
+			spotPriceOptions[i] = name
+			if label, exists := spotPriceEstimatorLabels[name]; exists {
+				spotPriceOptions[i] = label
+			}
+		}
+		name = names[utils.GetSimpleChoice("How should we estimate spot prices?", spotPriceOptions)]
+	}
+
+	estimator, err := billing.LookupSpotPriceEstimator(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return estimator
+}
+
+// selectFixedSpotPrice returns the fixed spot price for hybrid periods from flags or asks the user
+func selectFixedSpotPrice(opts options) float64 {
+	if opts.fixedSpotPriceSet {
+		if opts.fixedSpotPrice < 0 {
+			log.Fatalf("--fixed-spot-price must not be negative, got %.3f", opts.fixedSpotPrice)
+		}
+		return opts.fixedSpotPrice
+	}
+	if opts.nonInteractive {
+		log.Fatal("--fixed-spot-price is required in non-interactive mode for hybrid periods")
+	}
+
+	return billing.GetUserFixedSpotPrice()
+}
+
+// importConsumption reads the consumption of a Måledata export
+func importConsumption(filename, meterPointId string) []eloverblik.HourlyConsumption {
+	utils.PrintAction(fmt.Sprintf("Importing consumption data from %s...", filename))
+	imported, err := eloverblik.ImportConsumptionFile(filename, meterPointId)
+	if err != nil {
+		log.Fatal("Failed to import consumption data: ", err)
+	}
+
+	utils.PrintInfo(fmt.Sprintf("Imported %d intervals", len(imported)))
+	return imported
+}
+
+// importedConsumptionForPeriod returns the imported consumption of the billing period
+func importedConsumptionForPeriod(imported []eloverblik.HourlyConsumption, filename string, period billing.Period) []eloverblik.HourlyConsumption {
+	var consumptionData []eloverblik.HourlyConsumption
+	for _, interval := range imported {
+		if !interval.DateTime.Before(period.Start) && interval.DateTime.Before(period.End) {
+			consumptionData = append(consumptionData, interval)
+		}
+	}
+	if len(consumptionData) == 0 {
+		log.Fatalf("%s has no consumption in %s", filename, period.Label)
+	}
+
+	utils.PrintInfo(fmt.Sprintf("Using %d of %d imported intervals", len(consumptionData), len(imported)))
+	return consumptionData
+}
+
+// hasAuthToken reports whether an Eloverblik JWT token can be loaded
+func hasAuthToken(common *commonOptions) bool {
+	_, err := eloverblik.LoadAuthToken(common.authFile)
+	return err == nil
+}
+
+// cachedEloverblikClient returns a client serving only the Eloverblik data cached by earlier runs,
+// or nil if caching is disabled
+func cachedEloverblikClient(common *commonOptions) *eloverblik.Client {
+	store := common.store()
+	if store == nil {
+		return nil
+	}
+	store.Offline = true
+
+	client := eloverblik.NewClient("", "")
+	client.Cache = store
+	return client
+}
+
+// importedMeterPoint returns the meter point and details of a bill priced from --consumption-file
+// without Eloverblik access. They are read from the cache of an earlier run when available;
+// otherwise the meter point is --meter-point, its grid operator --grid-operator and the first
+// billing period the first complete month of the imported consumption.
+func importedMeterPoint(ctx context.Context, client *eloverblik.Client, opts options, imported []eloverblik.HourlyConsumption) (eloverblik.MeterPoint, eloverblik.MeterPointDetails) {
+	meterPoint := eloverblik.MeterPoint{ID: opts.meterPoint}
+	if client != nil {
+		if meterPoints, err := client.GetMeterPoints(ctx); err == nil {
+			for _, mp := range meterPoints {
+				if mp.ID == opts.meterPoint || opts.meterPoint == "" && len(meterPoints) == 1 {
+					meterPoint = mp
+				}
+			}
+		}
+	}
+
+	if meterPoint.ConsumerStartDate == "" {
+		first := imported[0].DateTime
+		for _, interval := range imported {
+			if interval.DateTime.Before(first) {
+				first = interval.DateTime
+			}
+		}
+		// Periods start in the first complete month after the consumer start date
+		meterPoint.ConsumerStartDate = first.Add(-time.Minute).UTC().Format("2006-01-02T15:04:05.000Z")
+	}
+
+	details := eloverblik.MeterPointDetails{Name: opts.gridOperator}
+	if client != nil && meterPoint.ID != "" {
+		if cached, err := client.GetMeterPointDetails(ctx, meterPoint.ID); err == nil {
+			details = cached
+		}
+	}
+	if details.Name == "" && opts.priceArea == "" {
+		log.Fatal("--price-area or --grid-operator is required when the meter point details are not cached")
+	}
+
+	utils.PrintSuccess(fmt.Sprintf("✓ Using the imported consumption of meter point %s", meterPoint.ID))
+	return meterPoint, details
+}
+
+// getCharges returns the tariffs and subscriptions of the meter point from --charges-file or Eloverblik
+func getCharges(ctx context.Context, client *eloverblik.Client, opts options, meterPointId string) *eloverblik.ChargesResult {
+	if opts.chargesFile != "" {
+		data, err := os.ReadFile(opts.chargesFile)
+		if err != nil {
+			log.Fatal("Failed to read charges: ", err)
+		}
+		var chargesData eloverblik.ChargesResult
+		if err = json.Unmarshal(data, &chargesData); err != nil {
+			log.Fatalf("Failed to parse charges in %s: %v", opts.chargesFile, err)
+		}
+		return &chargesData
+	}
+
+	if client == nil || meterPointId == "" {
+		log.Fatal("Without Eloverblik access the charges must be given with --charges-file")
+	}
+	chargesData, err := client.GetCharges(ctx, meterPointId)
+	if err != nil {
+		log.Fatal("Failed to get charges data (without Eloverblik access use --charges-file): ", err)
+	}
+	return chargesData
+}
+
+// runBill runs the complete bill calculation flow
+func runBill(ctx context.Context, args []string) {
+	opts := parseBillFlags(args)
+
+	ctx, cancel := opts.common.withDeadline(ctx)
+	defer cancel()
+
+	// Keep stdout free for the JSON document
+	if opts.output == "json" {
+		utils.Output = os.Stderr
+	}
+
+	// Authentication. An imported consumption file needs no Eloverblik token: the meter point,
+	// its details and charges then come from the cache of an earlier run or from flags.
+	utils.ClearConsole()
+	energinetClient := opts.common.newEnerginetClient()
+
+	var client *eloverblik.Client
+	var imported []eloverblik.HourlyConsumption
+	var selectedMeterPoint eloverblik.MeterPoint
+	var gridOperator eloverblik.MeterPointDetails
+	withoutEloverblik := opts.consumptionFile != "" && (opts.common.offline || !hasAuthToken(opts.common))
+	if withoutEloverblik {
+		utils.PrintInfo("No Eloverblik access: using the consumption file and cached meter point data")
+		client = cachedEloverblikClient(opts.common)
+		imported = importConsumption(opts.consumptionFile, opts.meterPoint)
+		selectedMeterPoint, gridOperator = importedMeterPoint(ctx, client, opts, imported)
+	} else {
+		client = authenticateUser(ctx, opts.common)
+
+		// Meter point selection
+		selectedMeterPoint = selectMeterPoint(ctx, client, opts)
+
+		// Get grid operator info
+		gridOperator = getGridOperatorInfo(ctx, client, selectedMeterPoint)
+
+		if opts.consumptionFile != "" {
+			imported = importConsumption(opts.consumptionFile, selectedMeterPoint.ID)
+		}
+	}
+
+	// Display details
+	displayMeterPointDetails(selectedMeterPoint, gridOperator)
+
+	utils.ClearConsole()
+
+	// Get calculation type
+	calculationType := selectCalculationType(opts)
+	utils.PrintSuccess(fmt.Sprintf("Selected calculation type: %s", calculationType))
+
+	// Get billing frequency
+	frequency := selectBillingFrequency(opts)
+	utils.PrintSuccess(fmt.Sprintf("Selected billing frequency: %s", frequency))
+
+	// Period selection with calculation type
+	utils.PrintAction("Generating available periods...")
+
+	// Parse consumer start date
+	consumerStartDate, err := time.Parse("2006-01-02T15:04:05.000Z", selectedMeterPoint.ConsumerStartDate)
+	if err != nil {
+		log.Fatal("Failed to parse consumer start date:", err)
+	}
+
+	// Generate available periods
+	periods, err := billing.GenerateAvailablePeriods(consumerStartDate, frequency, calculationType)
+	if err != nil {
+		log.Fatal("Failed to generate periods:", err)
+	}
+
+	if len(periods) == 0 {
+		if calculationType == billing.Historical {
+			utils.PrintWarning("No complete historical periods available for calculation yet.")
+		} else {
+			utils.PrintWarning("No aconto periods available.")
+		}
+		return
+	}
+
+	// Let user select period
+	selectedPeriod := selectPeriod(periods, opts)
+
+	utils.ClearConsole()
+
+	// Display selected period
+	billing.DisplaySelectedPeriod(selectedPeriod)
+
+	// NEW: Determine actual period type based on dates
+	periodType := billing.DeterminePeriodType(selectedPeriod, calculationType)
+	utils.PrintInfo(fmt.Sprintf("Detected period type: %s", periodType))
+	if periodType != billing.PeriodHistorical && withoutEloverblik && gridOperator.EstimatedAnnualVolume == 0 {
+		log.Fatal("Estimating the rest of the period needs the estimated annual volume from the meter point details; run once with Eloverblik access to cache them")
+	}
+
+	// Find price area for grid operator, unless given with --price-area
+	priceArea := opts.priceArea
+	if priceArea == "" {
+		gridMapping, err := billing.LoadGridCompaniesMapping("lib/billing/grid_companies.json")
+		if err != nil {
+			log.Fatal("Failed to load grid companies mapping:", err)
+		}
+
+		priceArea, err = billing.FindPriceArea(gridOperator.Name, gridMapping)
+		if err != nil {
+			log.Fatal("Failed to find price area:", err)
+		}
+	}
+
+	utils.PrintInfo(fmt.Sprintf("Grid operator: %s, Price area: %s", gridOperator.Name, priceArea))
+
+	// NEW: Branch based on detected period type
+	var consumptionData []eloverblik.HourlyConsumption
+	var totalConsumption float64
+	var spotPrices []energinet.SpotPriceRecord
+	var meteredKWh, estimatedKWh float64 // split of a hybrid period
+
+	// What the estimators of aconto and hybrid periods may use
+	estimationRequest := billing.EstimationRequest{
+		Start:                 selectedPeriod.Start,
+		End:                   selectedPeriod.End,
+		Frequency:             frequency,
+		EstimatedAnnualVolume: gridOperator.EstimatedAnnualVolume,
+		PriceArea:             priceArea,
+		MeterPointId:          selectedMeterPoint.ID,
+		ConsumerStartDate:     consumerStartDate,
+		Eloverblik:            client,
+		Energinet:             energinetClient,
+		MeteredConsumption:    imported,
+	}
+
+	switch periodType {
+	case billing.PeriodHistorical:
+		// Historical calculation
+		if opts.consumptionFile != "" {
+			consumptionData = importedConsumptionForPeriod(imported, opts.consumptionFile, selectedPeriod)
+		} else {
+			utils.PrintAction("Fetching actual consumption data...")
+			consumptionData, err = client.GetConsumptionForPeriod(
+				ctx,
+				selectedMeterPoint.ID,
+				selectedPeriod.Start,
+				selectedPeriod.End,
+				opts.aggregation,
+			)
+			if err != nil {
+				log.Fatal("Failed to get consumption data:", err)
+			}
+		}
+
+		totalConsumption = eloverblik.GetTotalConsumption(consumptionData)
+		summary := eloverblik.FormatConsumptionSummary(consumptionData)
+		utils.PrintInfo(summary)
+
+		// Fetch real spot prices in the resolution of the consumption
+		utils.PrintAction("Fetching spot prices...")
+		spotPrices, err = billing.FetchSpotPricesForPeriod(ctx, energinetClient, selectedPeriod.Start, selectedPeriod.End, priceArea, eloverblik.FinestResolution(consumptionData))
+		if err != nil {
+			log.Fatal("Failed to fetch spot prices:", err)
+		}
+		utils.PrintInfo(fmt.Sprintf("Fetched %d spot price records", len(spotPrices)))
+
+	case billing.PeriodAconto:
+		// Pure aconto calculation
+		utils.PrintAction("Estimating consumption for aconto calculation...")
+
+		// Ask about spot price method
+		spotPriceEstimator := selectSpotPriceEstimator(opts)
+
+		// Create aconto estimation
+		acontoEstimation, err := billing.CreateAcontoEstimation(ctx, estimationRequest, opts.consumption, spotPriceEstimator)
+		if err != nil {
+			log.Fatal("Failed to create aconto estimation:", err)
+		}
+
+		// Display estimation summary
+		billing.DisplayAcontoEstimationSummary(acontoEstimation, selectedPeriod)
+
+		// Use estimated data
+		consumptionData = acontoEstimation.EstimatedConsumption
+		totalConsumption = acontoEstimation.TotalEstimatedkWh
+		spotPrices = acontoEstimation.EstimatedSpotPrices
+
+		utils.PrintInfo(fmt.Sprintf("Generated %d hours of estimated consumption data", len(consumptionData)))
+
+	case billing.PeriodHybrid:
+		// NEW: Hybrid calculation
+		utils.PrintAction("Performing hybrid calculation (actual + estimated data)...")
+
+		// Estimate the spot prices of the remaining hours at a fixed price unless another estimator is chosen
+		var spotPriceEstimator billing.SpotPriceEstimator
+		if opts.spotMethod != "" && !strings.EqualFold(opts.spotMethod, "fixed") {
+			spotPriceEstimator = selectSpotPriceEstimator(opts)
+		} else {
+			spotPriceEstimator = billing.FixedSpotPrice{PriceDKKPerKWh: selectFixedSpotPrice(opts)}
+		}
+
+		// Create hybrid estimation
+		hybridEstimation, err := billing.CreateHybridEstimation(
+			ctx,
+			estimationRequest,
+			opts.aggregation,
+			opts.consumption,
+			spotPriceEstimator,
+		)
+		if err != nil {
+			log.Fatal("Failed to create hybrid estimation:", err)
+		}
+
+		// Display hybrid summary
+		billing.DisplayHybridEstimationSummary(hybridEstimation, selectedPeriod)
+
+		// Use combined data
+		consumptionData = hybridEstimation.CombinedConsumption
+		totalConsumption = hybridEstimation.TotalEstimatedkWh
+		spotPrices = hybridEstimation.CombinedSpotPrices
+
+		meteredKWh, estimatedKWh = hybridEstimation.ActualTotalKWh, hybridEstimation.EstimatedTotalKWh
+
+		utils.PrintInfo(fmt.Sprintf("Using %d hours of combined data (%d metered + %d estimated)",
+			hybridEstimation.ActualHours+hybridEstimation.EstimatedHours, hybridEstimation.ActualHours, hybridEstimation.EstimatedHours))
+	}
+
+	// Check the data before pricing it
+	completeness := billing.CheckCompleteness(consumptionData, spotPrices, selectedPeriod.Start, selectedPeriod.End)
+	if !completeness.IsComplete() {
+		for _, line := range completeness.Lines() {
+			utils.PrintWarning(line)
+		}
+		if opts.missingData == billing.CompletenessStrict {
+			log.Fatal("Incomplete data for the period; use --missing-data lenient to interpolate the missing hours")
+		}
+
+		utils.PrintWarning("Interpolating the missing hours; they are flagged in the output")
+		consumptionData, spotPrices = billing.RepairData(consumptionData, spotPrices, completeness)
+		totalConsumption = eloverblik.GetTotalConsumption(consumptionData)
+	}
+
+	// Continue with shared calculation logic
+	hourlyBreakdown := eloverblik.GetConsumptionByHour(consumptionData)
+	utils.PrintInfo(fmt.Sprintf("Data spread across %d different hours of day", len(hourlyBreakdown)))
+
+	utils.PrintAction("Fetching charges (tariffs and subscriptions)...")
+	chargesData := getCharges(ctx, client, opts, selectedMeterPoint.ID)
+
+	utils.PrintSuccess("Successfully retrieved charges data")
+
+	// Eloverblik only knows today's tariffs, so past hours need the tariffs valid at the time
+	if opts.tariffHistory && selectedPeriod.Start.Before(time.Now()) {
+		utils.PrintAction("Fetching historical tariffs...")
+		chargesData, err = billing.HistoricalCharges(ctx, energinetClient, chargesData, selectedPeriod.Start, selectedPeriod.End)
+		if err != nil {
+			log.Fatal("Failed to get historical tariffs:", err)
+		}
+	}
+
+	utils.PrintAction("Calculating complete electricity bill with spot prices...")
+
+	// Set supplier price based on calculation type
+	supplierPrice := 0.0 // No supplier cost for aconto/hybrid calculations
+	if periodType == billing.PeriodHistorical {
+		supplierPrice = 0.02 // 2 øre per kWh for historical calculations only
+	}
+
+	hourlyTariffCosts, err := billing.CalculateAllHourlyTariffs(consumptionData, chargesData, supplierPrice, spotPrices)
+	if err != nil {
+		log.Fatal("Failed to calculate the bill: ", err)
+	}
+
+	summary := billing.SummarizeBill(selectedPeriod, periodType, totalConsumption, hourlyTariffCosts, chargesData)
+	summary.MeteredKWh, summary.EstimatedKWh = meteredKWh, estimatedKWh
+	if !completeness.IsComplete() {
+		billing.MarkInterpolated(hourlyTariffCosts, completeness)
+		summary.Completeness = &completeness
+	}
+
+	if opts.output == "json" {
+		printJSON(summary)
+	} else {
+		displayBill(summary, gridOperator)
+	}
+
+	if opts.csvFile != "" {
+		if err := billing.ExportHourlyCSV(opts.csvFile, hourlyTariffCosts); err != nil {
+			log.Fatal("Failed to export hourly breakdown: ", err)
+		}
+		utils.PrintBlankLine()
+		utils.PrintSuccess(fmt.Sprintf("Hourly breakdown written to %s", opts.csvFile))
+	}
+}
+
+// displayBill prints the bill summary as coloured text
+func displayBill(summary *billing.BillSummary, gridOperator eloverblik.MeterPointDetails) {
+	// Display results with appropriate title based on period type
+	utils.ClearConsole()
+	switch summary.PeriodType {
+	case billing.PeriodHistorical:
+		utils.PrintSuccess(fmt.Sprintf("=== HISTORICAL ELECTRICITY BILL FOR %s ===", summary.Period))
+	case billing.PeriodAconto:
+		utils.PrintSuccess(fmt.Sprintf("=== ACONTO ELECTRICITY BILL ESTIMATE FOR %s ===", summary.Period))
+	case billing.PeriodHybrid:
+		utils.PrintSuccess(fmt.Sprintf("=== HYBRID ELECTRICITY BILL FOR %s ===", summary.Period))
+	}
+	utils.PrintBlankLine()
+
+	// Consumption summary
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+	utils.PrintInfo("CONSUMPTION SUMMARY:")
+	utils.PrintInfo(fmt.Sprintf("Period: %s to %s",
+		summary.PeriodStart.In(copenhagen).Format("2006-01-02"),
+		summary.PeriodEnd.AddDate(0, 0, -1).In(copenhagen).Format("2006-01-02")))
+
+	switch summary.PeriodType {
+	case billing.PeriodHistorical:
+		utils.PrintInfo(fmt.Sprintf("Total consumption: %.2f kWh (actual)", summary.TotalConsumptionKWh))
+	case billing.PeriodAconto:
+		utils.PrintInfo(fmt.Sprintf("Total consumption: %.2f kWh (estimated)", summary.TotalConsumptionKWh))
+		utils.PrintInfo(fmt.Sprintf("Based on estimated annual volume: %d kWh", gridOperator.EstimatedAnnualVolume))
+	case billing.PeriodHybrid:
+		utils.PrintInfo(fmt.Sprintf("Total consumption: %.2f kWh (%.2f kWh metered + %.2f kWh estimated)",
+			summary.TotalConsumptionKWh, summary.MeteredKWh, summary.EstimatedKWh))
+		utils.PrintInfo(fmt.Sprintf("Based on estimated annual volume: %d kWh", gridOperator.EstimatedAnnualVolume))
+	}
+	utils.PrintBlankLine()
+
+	// Usage-based charges
+	utils.PrintInfo("USAGE-BASED CHARGES:")
+	for name, cost := range summary.TariffCosts {
+		utils.PrintInfo(fmt.Sprintf("%-30s: %8.2f DKK", name, cost))
+	}
+	utils.PrintInfo(fmt.Sprintf("%-30s: %8.2f DKK", "Elleverandør", summary.SupplierCost))
+	utils.PrintInfo(fmt.Sprintf("%-30s: %8.2f DKK", "Spotpris", summary.SpotCost))
+	utils.PrintInfo(fmt.Sprintf("%-30s: %8.2f DKK", "Total usage charges", summary.TotalUsageCost))
+	utils.PrintBlankLine()
+
+	// Fixed charges
+	utils.PrintInfo("FIXED MONTHLY CHARGES:")
+	for name, cost := range summary.Subscriptions {
+		utils.PrintInfo(fmt.Sprintf("%-30s: %8.2f DKK", name, cost))
+	}
+	utils.PrintInfo(fmt.Sprintf("%-30s: %8.2f DKK", "Total subscriptions", summary.TotalSubscriptionCost))
+	utils.PrintBlankLine()
+
+	// Total bill
+	utils.PrintInfo("BILL SUMMARY:")
+	utils.PrintInfo(fmt.Sprintf("%-30s: %8.2f DKK", "Subtotal (excluding VAT)", summary.SubtotalExclVAT))
+	utils.PrintInfo(fmt.Sprintf("%-30s: %8.2f DKK", "VAT (25%)", summary.VAT))
+	utils.PrintSuccess(fmt.Sprintf("%-30s: %8.2f DKK", "TOTAL INCLUDING VAT", summary.TotalInclVAT))
+	utils.PrintBlankLine()
+
+	utils.PrintInfo(fmt.Sprintf("Average cost per kWh (incl. VAT): %.3f DKK", summary.AveragePricePerKWh))
+
+	if summary.Completeness != nil {
+		utils.PrintBlankLine()
+		utils.PrintWarning("INTERPOLATED DATA:")
+		for _, line := range summary.Completeness.Lines() {
+			utils.PrintWarning(line)
+		}
+	}
+
+	// Add appropriate disclaimers
+	if summary.PeriodType == billing.PeriodAconto || summary.PeriodType == billing.PeriodHybrid {
+		utils.PrintBlankLine()
+		utils.PrintWarning("ESTIMATION DISCLAIMER:")
+		utils.PrintWarning("This includes estimates based on industry-standard monthly/quarterly division.")
+		utils.PrintWarning("Actual consumption patterns and spot prices may vary significantly.")
+		utils.PrintWarning("Use this estimate for budgeting purposes only.")
+	}
+}
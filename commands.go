@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"electricity-invoice-calculator/lib/billing"
+	"electricity-invoice-calculator/lib/eloverblik"
+	"electricity-invoice-calculator/lib/energinet"
+	"electricity-invoice-calculator/lib/fakeapi"
+	"electricity-invoice-calculator/lib/utils"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// meterPointOutput is a meter point together with its grid operator details
+type meterPointOutput struct {
+	eloverblik.MeterPoint
+	Details eloverblik.MeterPointDetails `json:"details"`
+}
+
+// printJSON writes v as indented JSON to stdout
+func printJSON(v interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		log.Fatal("Failed to encode JSON: ", err)
+	}
+}
+
+// parseDateFlag parses a YYYY-MM-DD flag value as midnight in Copenhagen time
+func parseDateFlag(flagName, value string) time.Time {
+	requireFlag(flagName, value)
+
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+	date, err := time.ParseInLocation("2006-01-02", value, copenhagen)
+	if err != nil {
+		log.Fatalf("--%s must be a date in YYYY-MM-DD format, got %q", flagName, value)
+	}
+	return date
+}
+
+// parseAggregation converts a --resolution value to the aggregation of the Eloverblik time series endpoint
+func parseAggregation(resolution string) string {
+	switch resolution {
+	case "hour":
+		return eloverblik.AggregationHour
+	case "quarter":
+		return eloverblik.AggregationQuarter
+	case "actual":
+		return eloverblik.AggregationActual
+	default:
+		log.Fatalf("Invalid --resolution %q: use hour, quarter or actual", resolution)
+		return ""
+	}
+}
+
+// requireFlag stops the program when a required flag is empty
+func requireFlag(flagName, value string) {
+	if value == "" {
+		log.Fatalf("--%s is required", flagName)
+	}
+}
+
+// runMeterPoints lists all meter points with their grid operator details
+func runMeterPoints(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("meterpoints", flag.ExitOnError)
+	common := addCommonFlags(flags)
+	flags.Parse(args)
+	common.apply()
+
+	ctx, cancel := common.withDeadline(ctx)
+	defer cancel()
+
+	utils.Output = os.Stderr
+	client := authenticateUser(ctx, common)
+
+	utils.PrintAction("Getting meter points...")
+	meterPoints, err := client.GetMeterPoints(ctx)
+	if err != nil {
+		log.Fatal("Failed to get meter points: ", err)
+	}
+
+	output := make([]meterPointOutput, len(meterPoints))
+	for i, mp := range meterPoints {
+		utils.PrintAction(fmt.Sprintf("Getting details for %s...", mp.ID))
+		details, err := client.GetMeterPointDetails(ctx, mp.ID)
+		if err != nil {
+			log.Fatal("Failed to get meter point details: ", err)
+		}
+		output[i] = meterPointOutput{MeterPoint: mp, Details: details}
+	}
+
+	printJSON(output)
+}
+
+// runConsumption shows metered consumption for a meter point and period
+func runConsumption(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("consumption", flag.ExitOnError)
+	meterPoint := flags.String("meter-point", "", "meter point ID")
+	from := flags.String("from", "", "first day of the period (YYYY-MM-DD, inclusive)")
+	to := flags.String("to", "", "last day of the period (YYYY-MM-DD, exclusive)")
+	resolution := flags.String("resolution", "hour", "resolution of the consumption: hour, quarter or actual (as settled by the meter)")
+	common := addCommonFlags(flags)
+	flags.Parse(args)
+	common.apply()
+
+	ctx, cancel := common.withDeadline(ctx)
+	defer cancel()
+
+	requireFlag("meter-point", *meterPoint)
+	aggregation := parseAggregation(*resolution)
+	startDate := parseDateFlag("from", *from)
+	endDate := parseDateFlag("to", *to)
+
+	utils.Output = os.Stderr
+	client := authenticateUser(ctx, common)
+
+	utils.PrintAction("Fetching consumption data...")
+	consumptionData, err := client.GetConsumptionForPeriod(ctx, *meterPoint, startDate, endDate, aggregation)
+	if err != nil {
+		log.Fatal("Failed to get consumption data: ", err)
+	}
+	utils.PrintInfo(eloverblik.FormatConsumptionSummary(consumptionData))
+
+	printJSON(consumptionData)
+}
+
+// runPrices shows spot prices for a price area and period
+func runPrices(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("prices", flag.ExitOnError)
+	priceArea := flags.String("area", "", "price area: DK1 or DK2")
+	from := flags.String("from", "", "first day of the period (YYYY-MM-DD, inclusive)")
+	to := flags.String("to", "", "last day of the period (YYYY-MM-DD, exclusive)")
+	dataset := flags.String("dataset", "auto", "auto (prices in --resolution, switching to DayAheadPrices from 2025-10-01), Elspotprices or DayAheadPrices (raw records)")
+	resolution := flags.Duration("resolution", time.Hour, "resolution of the auto dataset: 1h or 15m (15-minute prices exist from 2025-10-01)")
+	common := addCommonFlags(flags)
+	flags.Parse(args)
+	common.apply()
+
+	ctx, cancel := common.withDeadline(ctx)
+	defer cancel()
+
+	requireFlag("area", *priceArea)
+	if common.offline && *dataset != "auto" {
+		log.Fatal("Only --dataset auto is cached and available with --offline")
+	}
+	if *resolution != time.Hour && *resolution != 15*time.Minute {
+		log.Fatalf("Invalid --resolution %s: use 1h or 15m", *resolution)
+	}
+	startDate := parseDateFlag("from", *from)
+	endDate := parseDateFlag("to", *to)
+
+	utils.Output = os.Stderr
+	utils.PrintAction("Fetching spot prices...")
+	client := common.newEnerginetClient()
+
+	var records any
+	var count int
+	var err error
+	switch *dataset {
+	case "auto":
+		var spotPrices []energinet.SpotPriceRecord
+		spotPrices, err = billing.FetchSpotPricesForPeriod(ctx, client, startDate, endDate, *priceArea, *resolution)
+		records, count = spotPrices, len(spotPrices)
+	case "Elspotprices":
+		var spotPrices []energinet.SpotPriceRecord
+		spotPrices, err = client.GetSpotPrices(ctx, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"), []string{*priceArea})
+		records, count = spotPrices, len(spotPrices)
+	case "DayAheadPrices":
+		var dayAheadPrices []energinet.DayAheadPriceRecord
+		dayAheadPrices, err = client.GetDayAheadPrices(ctx, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"), []string{*priceArea})
+		records, count = dayAheadPrices, len(dayAheadPrices)
+	default:
+		log.Fatalf("Invalid --dataset %q: use auto, Elspotprices or DayAheadPrices", *dataset)
+	}
+	if err != nil {
+		log.Fatal("Failed to fetch spot prices: ", err)
+	}
+	utils.PrintInfo(fmt.Sprintf("Fetched %d spot price records", count))
+
+	printJSON(records)
+}
+
+// runCharges shows tariffs and subscriptions for a meter point
+func runCharges(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("charges", flag.ExitOnError)
+	meterPoint := flags.String("meter-point", "", "meter point ID")
+	common := addCommonFlags(flags)
+	flags.Parse(args)
+	common.apply()
+
+	ctx, cancel := common.withDeadline(ctx)
+	defer cancel()
+
+	requireFlag("meter-point", *meterPoint)
+
+	utils.Output = os.Stderr
+	client := authenticateUser(ctx, common)
+
+	utils.PrintAction("Fetching charges (tariffs and subscriptions)...")
+	chargesData, err := client.GetCharges(ctx, *meterPoint)
+	if err != nil {
+		log.Fatal("Failed to get charges data: ", err)
+	}
+
+	printJSON(chargesData)
+}
+
+// runServeFake serves the fake Eloverblik and Energi Data Service APIs until interrupted
+func runServeFake(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("serve-fake", flag.ExitOnError)
+	addr := flags.String("addr", "127.0.0.1:8080", "address to listen on")
+	fixturesDir := flags.String("fixtures", "", "directory with fixture files (default: built-in fixtures)")
+	flags.Parse(args)
+
+	var fixtures *fakeapi.Fixtures
+	var err error
+	if *fixturesDir != "" {
+		fixtures, err = fakeapi.LoadFixturesDir(*fixturesDir)
+	} else {
+		fixtures, err = fakeapi.DefaultFixtures()
+	}
+	if err != nil {
+		log.Fatal("Failed to load fixtures: ", err)
+	}
+
+	server := &http.Server{Addr: *addr, Handler: fakeapi.NewHandler(fixtures)}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	serverURL := "http://" + *addr
+	utils.PrintSuccess(fmt.Sprintf("Fake APIs listening on %s", serverURL))
+	utils.PrintInfo("Use them with:")
+	utils.PrintInfo(fmt.Sprintf("  --eloverblik-url %s --energidataservice-url %s",
+		fakeapi.EloverblikURL(serverURL), fakeapi.EnerginetURL(serverURL)))
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal("Fake server failed: ", err)
+	}
+}
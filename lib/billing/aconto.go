@@ -1,9 +1,12 @@
 package billing
 
 import (
+	"context"
+	"electricity-invoice-calculator/lib/cache"
 	"electricity-invoice-calculator/lib/eloverblik"
 	"electricity-invoice-calculator/lib/energinet"
 	"electricity-invoice-calculator/lib/utils"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -43,14 +46,16 @@ type HybridEstimation struct {
 	// Kombinerede data (til videre beregninger)
 	CombinedConsumption []eloverblik.HourlyConsumption
 	CombinedSpotPrices  []energinet.SpotPriceRecord
-	TotalEstimatedkWh   float64
+	TotalEstimatedkWh   float64 // Samlet forbrug (målt + estimeret)
 
 	// Metadata
-	SplitDateTime    time.Time // Hvor delingen sker (nu-tidspunkt)
-	EstimationMethod string    // Beskrivelse af metode
-	ActualHours      int       // Antal faktiske timer
-	EstimatedHours   int       // Antal estimerede timer
-	FixedSpotPrice   float64   // Fast pris for estimerede timer
+	SplitDateTime          time.Time // Hvor målt forbrug slutter og estimeret forbrug begynder
+	SpotPriceSplitDateTime time.Time // Hvor faktiske spotpriser slutter og estimerede spotpriser begynder
+	EstimationMethod       string    // Beskrivelse af metode
+	ConsumptionDescription string    // Beskrivelse af estimeringen af forbrug for resten af perioden
+	ActualHours            int       // Antal timer med målt forbrug
+	EstimatedHours         int       // Antal timer med estimeret forbrug
+	SpotPriceMethod        string    // Beskrivelse af estimeringen af spotpriser for resten af perioden
 }
 
 // DeterminePeriodType finder ud af hvilken type periode vi har
@@ -108,6 +113,7 @@ func EstimateConsumptionForPeriod(estimatedAnnualVolume int, startDate, endDate
 	for currentTime.Before(endDate) {
 		hourlyData := eloverblik.HourlyConsumption{
 			DateTime:    currentTime,
+			Resolution:  time.Hour,
 			Consumption: avgHourlyConsumption,
 			Quality:     "ESTIMATED", // Mark as estimated data
 		}
@@ -119,62 +125,54 @@ func EstimateConsumptionForPeriod(estimatedAnnualVolume int, startDate, endDate
 	return estimatedConsumption, nil
 }
 
-// EstimateSpotPricesForPeriod creates estimated spot prices for the aconto period
-// using a fixed estimate based on reasonable market averages
-func EstimateSpotPricesForPeriod(startDate, endDate time.Time, priceArea string) ([]energinet.SpotPriceRecord, error) {
-	// Use a simple fixed spot price estimate in DKK/kWh
-	estimatedSpotPriceDKKPerKWh := 0.614029 // 0.61 DKK/kWh as a reasonable estimate
-
-	// Convert to MWh for the SpotPriceRecord (since that's what the struct expects)
-	estimatedSpotPriceDKKPerMWh := estimatedSpotPriceDKKPerKWh * 1000.0 // Convert kWh to MWh
-
-	var estimatedSpotPrices []energinet.SpotPriceRecord
-
-	// Create spot price records for each hour in the period
-	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
-	currentTime := startDate
-
-	for currentTime.Before(endDate) {
-		// Format times for the spot price record
-		hourUTC := currentTime.UTC().Format("2006-01-02T15:04:05")
-		hourDK := currentTime.In(copenhagen).Format("2006-01-02T15:04:05")
-
-		spotRecord := energinet.SpotPriceRecord{
-			HourUTC:      hourUTC,
-			HourDK:       hourDK,
-			PriceArea:    priceArea,
-			SpotPriceDKK: estimatedSpotPriceDKKPerMWh,
-			SpotPriceEUR: estimatedSpotPriceDKKPerMWh / 7.45, // Rough EUR conversion
-		}
-
-		estimatedSpotPrices = append(estimatedSpotPrices, spotRecord)
-		currentTime = currentTime.Add(1 * time.Hour)
-	}
-
-	return estimatedSpotPrices, nil
-}
-
 // EstimateHistoricalSpotPricesForPeriod gets actual spot prices from the same period last year
-func EstimateHistoricalSpotPricesForPeriod(startDate, endDate time.Time, priceArea string) ([]energinet.SpotPriceRecord, error) {
+func EstimateHistoricalSpotPricesForPeriod(ctx context.Context, client *energinet.Client, startDate, endDate time.Time, priceArea string) ([]energinet.SpotPriceRecord, error) {
 	// Get same period from previous year
 	lastYearStart := startDate.AddDate(-1, 0, 0)
 	lastYearEnd := endDate.AddDate(-1, 0, 0)
 
 	// Fetch historical spot prices
-	historicalSpotPrices, err := FetchSpotPricesForPeriod(lastYearStart, lastYearEnd, priceArea)
+	historicalSpotPrices, err := FetchSpotPricesForPeriod(ctx, client, lastYearStart, lastYearEnd, priceArea, time.Hour)
 	if err != nil {
 		// Return error, let caller decide fallback strategy
 		return nil, fmt.Errorf("could not fetch historical spot prices: %v", err)
 	}
 
+	// Index last year's prices by UTC instant, so the order of the records and the
+	// 23- and 25-hour days of daylight saving time do not shift the prices
+	historicalByInstant := make(map[int64]energinet.SpotPriceRecord, len(historicalSpotPrices))
+	for _, record := range historicalSpotPrices {
+		recordTime, err := time.ParseInLocation(energinet.RecordTimeLayout, record.HourUTC, time.UTC)
+		if err != nil {
+			continue
+		}
+		historicalByInstant[recordTime.Unix()] = record
+	}
+
 	// Adjust the dates to current year but keep the prices
 	var adjustedSpotPrices []energinet.SpotPriceRecord
 	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
 
-	currentTime := startDate
-	historicalIndex := 0
+	for currentTime := startDate; currentTime.Before(endDate); currentTime = currentTime.Add(1 * time.Hour) {
+		// Same hour of the day last year, counted from midnight so both 02:00 hours of the 25-hour
+		// day get their own price. Hours past the end of a shorter day last year take its last hour.
+		local := currentTime.In(copenhagen)
+		day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, copenhagen)
+		lastYearDay := day.AddDate(-1, 0, 0)
+		lastYear := lastYearDay.Add(currentTime.Sub(day))
+		if lastYearDayEnd := lastYearDay.AddDate(0, 0, 1); !lastYear.Before(lastYearDayEnd) {
+			lastYear = lastYearDayEnd.Add(-time.Hour)
+		}
+
+		// The hour before it if last year has no price for it
+		historical, exists := historicalByInstant[lastYear.Unix()]
+		if !exists {
+			historical, exists = historicalByInstant[lastYear.Add(-time.Hour).Unix()]
+		}
+		if !exists {
+			continue
+		}
 
-	for currentTime.Before(endDate) && historicalIndex < len(historicalSpotPrices) {
 		// Format times for current year
 		hourUTC := currentTime.UTC().Format("2006-01-02T15:04:05")
 		hourDK := currentTime.In(copenhagen).Format("2006-01-02T15:04:05")
@@ -184,13 +182,11 @@ func EstimateHistoricalSpotPricesForPeriod(startDate, endDate time.Time, priceAr
 			HourUTC:      hourUTC,
 			HourDK:       hourDK,
 			PriceArea:    priceArea,
-			SpotPriceDKK: historicalSpotPrices[historicalIndex].SpotPriceDKK,
-			SpotPriceEUR: historicalSpotPrices[historicalIndex].SpotPriceEUR,
+			SpotPriceDKK: historical.SpotPriceDKK,
+			SpotPriceEUR: historical.SpotPriceEUR,
 		}
 
 		adjustedSpotPrices = append(adjustedSpotPrices, adjustedRecord)
-		currentTime = currentTime.Add(1 * time.Hour)
-		historicalIndex++
 	}
 
 	if len(adjustedSpotPrices) == 0 {
@@ -201,35 +197,24 @@ func EstimateHistoricalSpotPricesForPeriod(startDate, endDate time.Time, priceAr
 }
 
 // CreateAcontoEstimation creates a complete estimation for aconto calculation
-func CreateAcontoEstimation(estimatedAnnualVolume int, startDate, endDate time.Time, priceArea string, frequency BillingFrequency, useHistoricalPrices bool) (*AcontoEstimation, error) {
+// of [request.Start, request.End) with the given estimators
+func CreateAcontoEstimation(ctx context.Context, request EstimationRequest, consumptionEstimator ConsumptionEstimator, spotPriceEstimator SpotPriceEstimator) (*AcontoEstimation, error) {
 	// Estimate consumption
-	estimatedConsumption, err := EstimateConsumptionForPeriod(estimatedAnnualVolume, startDate, endDate, frequency)
+	estimatedConsumption, consumptionDescription, err := consumptionEstimator.EstimateConsumption(ctx, request)
 	if err != nil {
 		return nil, fmt.Errorf("failed to estimate consumption: %v", err)
 	}
+	if len(estimatedConsumption) == 0 {
+		return nil, fmt.Errorf("consumption estimator %q returned no hours", consumptionEstimator.Name())
+	}
 
 	// Estimate spot prices
-	var estimatedSpotPrices []energinet.SpotPriceRecord
-	var estimationMethod string
-
-	if useHistoricalPrices {
-		estimatedSpotPrices, err = EstimateHistoricalSpotPricesForPeriod(startDate, endDate, priceArea)
-		if err != nil {
-			// Fallback to fixed estimate if historical data fails
-			estimatedSpotPrices, err = EstimateSpotPricesForPeriod(startDate, endDate, priceArea)
-			if err != nil {
-				return nil, fmt.Errorf("failed to estimate spot prices: %v", err)
-			}
-			estimationMethod = "Fixed spot price estimate (historical data unavailable)"
-		} else {
-			estimationMethod = "Historical spot prices from same period last year"
-		}
-	} else {
-		estimatedSpotPrices, err = EstimateSpotPricesForPeriod(startDate, endDate, priceArea)
-		if err != nil {
-			return nil, fmt.Errorf("failed to estimate spot prices: %v", err)
-		}
-		estimationMethod = "Fixed spot price estimate"
+	estimatedSpotPrices, estimationMethod, err := spotPriceEstimator.EstimateSpotPrices(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate spot prices: %v", err)
+	}
+	if len(estimatedSpotPrices) == 0 {
+		return nil, fmt.Errorf("spot price estimator %q returned no prices", spotPriceEstimator.Name())
 	}
 
 	// Calculate statistics
@@ -249,7 +234,7 @@ func CreateAcontoEstimation(estimatedAnnualVolume int, startDate, endDate time.T
 	return &AcontoEstimation{
 		EstimatedConsumption: estimatedConsumption,
 		EstimatedSpotPrices:  estimatedSpotPrices,
-		EstimationMethod:     estimationMethod,
+		EstimationMethod:     consumptionDescription + "; " + estimationMethod,
 		TotalEstimatedkWh:    totalEstimated,
 		AvgHourlyConsumption: avgHourlyConsumption,
 		AvgSpotPrice:         avgSpotPrice,
@@ -257,139 +242,143 @@ func CreateAcontoEstimation(estimatedAnnualVolume int, startDate, endDate time.T
 	}, nil
 }
 
-// CreateHybridEstimation laver en hybrid beregning med estimeret forbrug + blandet spotpriser
+// CreateHybridEstimation laver en hybrid beregning af request.Start til request.End: målt forbrug
+// fra Eloverblik (eller request.MeteredConsumption) for den forløbne del af perioden og estimeret forbrug for resten, med faktiske
+// spotpriser frem til to dage før nu (eller til målingernes slutning, hvis den er senere) og
+// estimerede spotpriser derefter
 func CreateHybridEstimation(
-	estimatedAnnualVolume int,
-	period Period,
-	frequency BillingFrequency,
-	refreshToken string,
-	meterPointId string,
-	priceArea string,
-	fixedSpotPrice float64, // Fast pris for estimerede timer (DKK/kWh)
+	ctx context.Context,
+	request EstimationRequest,
+	aggregation string, // Opløsning af det målte forbrug, se eloverblik.AggregationActual
+	consumptionEstimator ConsumptionEstimator, // Estimering af forbruget for resten af perioden
+	spotPriceEstimator SpotPriceEstimator, // Estimering af spotpriserne for resten af perioden
 ) (*HybridEstimation, error) {
+	period := Period{Start: request.Start, End: request.End}
 
 	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
 	now := time.Now().In(copenhagen)
 
-	// Definer split-tidspunktet for spotpriser (nu minus 2 dage, rounded til nærmeste time)
-	spotPriceSplitDateTime := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, copenhagen).AddDate(0, 0, -2)
-
-	// STEP 1: Generer ALT forbrug baseret på annual consumption (ikke faktisk forbrug)
-	// Dette sikrer at aconto altid baserer sig på estimatet
-	allEstimatedConsumption, err := EstimateConsumptionForPeriod(estimatedAnnualVolume, period.Start, period.End, frequency)
-	if err != nil {
-		return nil, fmt.Errorf("kunne ikke estimere forbrug for periode: %v", err)
+	// STEP 1: Hent målt forbrug fra periodens start til og med i går.
+	// Eloverblik har typisk data frem til 1-2 dage før nu.
+	meterDataEnd := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, copenhagen)
+	if meterDataEnd.After(period.End) {
+		meterDataEnd = period.End
 	}
 
-	// STEP 2: Hent faktiske spotpriser fra periodens start til nu minus 2 dage
-	var actualSpotPrices []energinet.SpotPriceRecord
-	var estimatedSpotPrices []energinet.SpotPriceRecord
-
-	if spotPriceSplitDateTime.After(period.Start) {
-		// Hent faktiske spotpriser for den del hvor de er tilgængelige
-		actualSpotPrices, err = FetchSpotPricesForPeriod(period.Start, spotPriceSplitDateTime, priceArea)
-		if err != nil {
-			return nil, fmt.Errorf("kunne ikke hente faktiske spotpriser: %v", err)
+	var actualConsumption []eloverblik.HourlyConsumption
+	if request.MeteredConsumption != nil {
+		// Forbrug importeret fra en fil
+		for _, interval := range request.MeteredConsumption {
+			if !interval.DateTime.Before(period.Start) && interval.DateTime.Before(meterDataEnd) {
+				actualConsumption = append(actualConsumption, interval)
+			}
 		}
-
-		// Generer faste spotpriser for resten af perioden
-		estimatedSpotPrices = generateFixedSpotPricesForPeriod(
-			spotPriceSplitDateTime,
-			period.End,
-			priceArea,
-			fixedSpotPrice,
-		)
-	} else {
-		// Hele perioden er i fremtiden - brug kun faste priser
-		estimatedSpotPrices = generateFixedSpotPricesForPeriod(
-			period.Start,
-			period.End,
-			priceArea,
-			fixedSpotPrice,
-		)
+	} else if meterDataEnd.After(period.Start) {
+		var err error
+		actualConsumption, err = request.Eloverblik.GetConsumptionForPeriod(ctx, request.MeterPointId, period.Start, meterDataEnd, aggregation)
+		var missing *cache.MissingError
+		if err != nil && !errors.As(err, &missing) {
+			return nil, fmt.Errorf("kunne ikke hente målt forbrug: %v", err)
+		}
+		// Offline bruges det forbrug der ligger i cachen; resten estimeres
 	}
 
-	// STEP 3: Kombiner spotpriser
-	combinedSpotPrices := append(actualSpotPrices, estimatedSpotPrices...)
-
-	// STEP 4: Beregn statistikker
-	totalEstimatedkWh := 0.0
-	for _, hourly := range allEstimatedConsumption {
-		totalEstimatedkWh += hourly.Consumption
+	// Forbruget er målt frem til slutningen af det sidste målte interval
+	consumptionSplitDateTime := period.Start
+	for _, interval := range actualConsumption {
+		if intervalEnd := interval.DateTime.Add(interval.Resolution); intervalEnd.After(consumptionSplitDateTime) {
+			consumptionSplitDateTime = intervalEnd
+		}
 	}
 
-	// STEP 5: Del forbrugsdata op i "faktisk" og "estimeret" baseret på tidspunkt (kun til visning)
-	// Men begge dele baserer sig på annual consumption estimation
-	currentDateTime := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, copenhagen)
-
-	var pastConsumption []eloverblik.HourlyConsumption
-	var futureConsumption []eloverblik.HourlyConsumption
-	pastTotalKWh := 0.0
-	futureTotalKWh := 0.0
+	// STEP 2: Estimer forbruget for hele perioden og brug det for timerne uden målinger
+	allEstimatedConsumption, consumptionDescription, err := consumptionEstimator.EstimateConsumption(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("kunne ikke estimere forbrug for periode: %v", err)
+	}
 
+	var estimatedConsumption []eloverblik.HourlyConsumption
 	for _, hourly := range allEstimatedConsumption {
-		if hourly.DateTime.Before(currentDateTime) {
-			// Mark som "faktisk periode" selvom det er estimeret
-			hourlyPast := hourly
-			hourlyPast.Quality = "ESTIMATED_PAST"
-			pastConsumption = append(pastConsumption, hourlyPast)
-			pastTotalKWh += hourly.Consumption
-		} else {
-			// Mark som "fremtidig periode"
-			hourlyFuture := hourly
-			hourlyFuture.Quality = "ESTIMATED_FUTURE"
-			futureConsumption = append(futureConsumption, hourlyFuture)
-			futureTotalKWh += hourly.Consumption
+		if !hourly.DateTime.Before(consumptionSplitDateTime) {
+			hourly.Quality = "ESTIMATED_FUTURE"
+			estimatedConsumption = append(estimatedConsumption, hourly)
 		}
 	}
 
-	// STEP 6: Lav estimations-objekt
-	estimation := &HybridEstimation{
-		ActualConsumption:    pastConsumption,         // "Faktisk periode" men estimeret forbrug
-		ActualSpotPrices:     actualSpotPrices,        // Faktiske spotpriser
-		ActualTotalKWh:       pastTotalKWh,            // Estimeret forbrug for faktisk periode
-		EstimatedConsumption: futureConsumption,       // Fremtidig periode
-		EstimatedSpotPrices:  estimatedSpotPrices,     // Faste spotpriser
-		EstimatedTotalKWh:    futureTotalKWh,          // Estimeret forbrug for fremtidige timer
-		CombinedConsumption:  allEstimatedConsumption, // Alt forbrug (estimeret)
-		CombinedSpotPrices:   combinedSpotPrices,      // Faktiske + faste spotpriser
-		TotalEstimatedkWh:    totalEstimatedkWh,       // Total estimeret forbrug
-		SplitDateTime:        currentDateTime,         // Nuværende tidspunkt
-		ActualHours:          len(pastConsumption),    // Timer i "faktisk" periode
-		EstimatedHours:       len(futureConsumption),  // Timer i fremtidige periode
-		FixedSpotPrice:       fixedSpotPrice,
-		EstimationMethod: fmt.Sprintf("Hybrid aconto: %d timer med faktiske spotpriser + %d timer med fast pris (alt forbrug estimeret)",
-			len(actualSpotPrices),
-			len(estimatedSpotPrices)),
+	// STEP 3: Hent faktiske spotpriser fra periodens start til nu minus 2 dage,
+	// dog mindst for alle timer med målt forbrug
+	spotPriceSplitDateTime := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, copenhagen).AddDate(0, 0, -2)
+	if consumptionSplitDateTime.After(spotPriceSplitDateTime) {
+		spotPriceSplitDateTime = consumptionSplitDateTime
+	}
+	if spotPriceSplitDateTime.Before(period.Start) {
+		spotPriceSplitDateTime = period.Start
 	}
 
-	return estimation, nil
-}
+	var actualSpotPrices []energinet.SpotPriceRecord
+	if spotPriceSplitDateTime.After(period.Start) {
+		// Spotpriser i samme opløsning som det målte forbrug
+		resolution := time.Hour
+		if len(actualConsumption) > 0 {
+			resolution = eloverblik.FinestResolution(actualConsumption)
+		}
 
-// generateEstimatedConsumptionForRemainingPeriod laver jævnt fordelt forbrug for resten af perioden
-func generateEstimatedConsumptionForRemainingPeriod(startTime, endTime time.Time, totalKWh float64) ([]eloverblik.HourlyConsumption, error) {
-	duration := endTime.Sub(startTime)
-	hoursRemaining := int(duration.Hours())
+		actualSpotPrices, err = FetchSpotPricesForPeriod(ctx, request.Energinet, period.Start, spotPriceSplitDateTime, request.PriceArea, resolution)
+		if err != nil {
+			return nil, fmt.Errorf("kunne ikke hente faktiske spotpriser: %v", err)
+		}
+	}
 
-	if hoursRemaining <= 0 {
-		return []eloverblik.HourlyConsumption{}, nil
+	// Estimerede spotpriser for resten af perioden
+	var estimatedSpotPrices []energinet.SpotPriceRecord
+	spotPriceDescription := "ingen"
+	if spotPriceSplitDateTime.Before(period.End) {
+		remaining := request
+		remaining.Start = spotPriceSplitDateTime
+		estimatedSpotPrices, spotPriceDescription, err = spotPriceEstimator.EstimateSpotPrices(ctx, remaining)
+		if err != nil {
+			return nil, fmt.Errorf("kunne ikke estimere spotpriser: %v", err)
+		}
 	}
 
-	avgHourlyConsumption := totalKWh / float64(hoursRemaining)
+	// STEP 4: Kombiner data
+	combinedConsumption := append(append([]eloverblik.HourlyConsumption{}, actualConsumption...), estimatedConsumption...)
+	combinedSpotPrices := append(append([]energinet.SpotPriceRecord{}, actualSpotPrices...), estimatedSpotPrices...)
 
-	var estimatedData []eloverblik.HourlyConsumption
-	currentTime := startTime
+	actualTotalKWh := eloverblik.GetTotalConsumption(actualConsumption)
+	estimatedTotalKWh := eloverblik.GetTotalConsumption(estimatedConsumption)
 
-	for currentTime.Before(endTime) {
-		estimatedData = append(estimatedData, eloverblik.HourlyConsumption{
-			DateTime:    currentTime,
-			Consumption: avgHourlyConsumption,
-			Quality:     "ESTIMATED_HYBRID",
-		})
-		currentTime = currentTime.Add(1 * time.Hour)
+	actualHours := len(eloverblik.SumByHour(actualConsumption))
+	actualSpotHours := int(spotPriceSplitDateTime.Sub(period.Start).Hours())
+	estimatedSpotHours := int(period.End.Sub(spotPriceSplitDateTime).Hours())
+
+	// STEP 5: Lav estimations-objekt
+	estimation := &HybridEstimation{
+		ActualConsumption:      actualConsumption,                  // Målt forbrug
+		ActualSpotPrices:       actualSpotPrices,                   // Faktiske spotpriser
+		ActualTotalKWh:         actualTotalKWh,                     // Målt forbrug i alt
+		EstimatedConsumption:   estimatedConsumption,               // Estimeret forbrug for resten af perioden
+		EstimatedSpotPrices:    estimatedSpotPrices,                // Estimerede spotpriser
+		EstimatedTotalKWh:      estimatedTotalKWh,                  // Estimeret forbrug i alt
+		CombinedConsumption:    combinedConsumption,                // Målt + estimeret forbrug
+		CombinedSpotPrices:     combinedSpotPrices,                 // Faktiske + estimerede spotpriser
+		TotalEstimatedkWh:      actualTotalKWh + estimatedTotalKWh, // Samlet forbrug
+		SplitDateTime:          consumptionSplitDateTime,           // Slutningen af det målte forbrug
+		SpotPriceSplitDateTime: spotPriceSplitDateTime,             // Slutningen af de faktiske spotpriser
+		ActualHours:            actualHours,                        // Timer med målt forbrug
+		EstimatedHours:         len(estimatedConsumption),          // Timer med estimeret forbrug
+		ConsumptionDescription: consumptionDescription,
+		SpotPriceMethod:        spotPriceDescription,
+		EstimationMethod: fmt.Sprintf("Hybrid aconto: %d timer med målt forbrug + %d timer med estimeret forbrug (%s); %d timer med faktiske spotpriser + %d timer med estimerede spotpriser (%s)",
+			actualHours,
+			len(estimatedConsumption),
+			consumptionDescription,
+			actualSpotHours,
+			estimatedSpotHours,
+			spotPriceDescription),
 	}
 
-	return estimatedData, nil
+	return estimation, nil
 }
 
 // generateFixedSpotPricesForPeriod laver faste spotpriser for den estimerede periode
@@ -411,7 +400,7 @@ func generateFixedSpotPricesForPeriod(startTime, endTime time.Time, priceArea st
 			HourDK:       hourDK,
 			PriceArea:    priceArea,
 			SpotPriceDKK: fixedPriceMWh,
-			SpotPriceEUR: fixedPriceMWh / 7.45,
+			SpotPriceEUR: fixedPriceMWh / energinet.EURToDKK,
 		})
 
 		currentTime = currentTime.Add(1 * time.Hour)
@@ -457,46 +446,49 @@ func DisplayAcontoEstimationSummary(estimation *AcontoEstimation, period Period)
 	utils.PrintInfo(fmt.Sprintf("Average hourly consumption: %.4f kWh", estimation.AvgHourlyConsumption))
 	utils.PrintInfo(fmt.Sprintf("Hours in period: %d", estimation.HoursInPeriod))
 	utils.PrintInfo(fmt.Sprintf("Average estimated spot price: %.3f DKK/kWh", estimation.AvgSpotPrice))
-	utils.PrintWarning("Note: This is an estimate using industry-standard monthly/quarterly division")
-	fmt.Println()
+	utils.PrintWarning("Note: This is an estimate, not metered consumption")
+	utils.PrintBlankLine()
 }
 
 // DisplayHybridEstimationSummary viser sammendrag af hybrid beregning
 func DisplayHybridEstimationSummary(estimation *HybridEstimation, period Period) {
 	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
 
-	// Beregn spotpris split-punkt (2 dage før nuværende tidspunkt)
-	spotPriceSplitDateTime := estimation.SplitDateTime.AddDate(0, 0, -2)
-
 	utils.PrintInfo("=== HYBRID ACONTO BEREGNING SAMMENDRAG ===")
 	utils.PrintInfo(fmt.Sprintf("Periode: %s", period.Label))
-	utils.PrintInfo(fmt.Sprintf("Nuværende tidspunkt: %s", estimation.SplitDateTime.In(copenhagen).Format("2006-01-02 15:04")))
-	utils.PrintInfo(fmt.Sprintf("Spotpris split-punkt: %s", spotPriceSplitDateTime.In(copenhagen).Format("2006-01-02 15:04")))
-	fmt.Println()
+	utils.PrintInfo(fmt.Sprintf("Målt forbrug til: %s", estimation.SplitDateTime.In(copenhagen).Format("2006-01-02 15:04")))
+	utils.PrintInfo(fmt.Sprintf("Spotpris split-punkt: %s", estimation.SpotPriceSplitDateTime.In(copenhagen).Format("2006-01-02 15:04")))
+	utils.PrintBlankLine()
 
-	utils.PrintInfo("FORBRUGTE TIMER (estimeret forbrug + faktiske spotpriser):")
+	utils.PrintInfo("MÅLTE TIMER (faktisk forbrug fra Eloverblik):")
 	utils.PrintInfo(fmt.Sprintf("Antal timer: %d", estimation.ActualHours))
-	utils.PrintInfo(fmt.Sprintf("Estimeret forbrug: %.2f kWh", estimation.ActualTotalKWh))
-	utils.PrintInfo(fmt.Sprintf("Faktiske spotpriser: %d timer", len(estimation.ActualSpotPrices)))
-	if len(estimation.ActualSpotPrices) > 0 {
-		actualAvgSpot := calculateAverageSpotPrice(estimation.ActualSpotPrices)
-		utils.PrintInfo(fmt.Sprintf("Gennemsnitlig faktisk spotpris: %.3f DKK/kWh", actualAvgSpot))
-	}
-	fmt.Println()
+	utils.PrintInfo(fmt.Sprintf("Målt forbrug: %.2f kWh", estimation.ActualTotalKWh))
+	utils.PrintBlankLine()
 
-	utils.PrintInfo("FREMTIDIGE TIMER (estimeret forbrug + fast spotpris):")
+	utils.PrintInfo(fmt.Sprintf("RESTERENDE TIMER (estimeret forbrug: %s):", estimation.ConsumptionDescription))
 	utils.PrintInfo(fmt.Sprintf("Antal timer: %d", estimation.EstimatedHours))
 	utils.PrintInfo(fmt.Sprintf("Estimeret forbrug: %.2f kWh", estimation.EstimatedTotalKWh))
-	utils.PrintInfo(fmt.Sprintf("Faste spotpriser: %d timer", len(estimation.EstimatedSpotPrices)))
-	utils.PrintInfo(fmt.Sprintf("Fast spotpris: %.3f DKK/kWh", estimation.FixedSpotPrice))
-	fmt.Println()
+	utils.PrintBlankLine()
+
+	utils.PrintInfo("SPOTPRISER:")
+	if len(estimation.ActualSpotPrices) > 0 {
+		actualAvgSpot := calculateAverageSpotPrice(estimation.ActualSpotPrices)
+		utils.PrintInfo(fmt.Sprintf("Faktiske spotpriser til %s: gennemsnit %.3f DKK/kWh",
+			estimation.SpotPriceSplitDateTime.In(copenhagen).Format("2006-01-02 15:04"), actualAvgSpot))
+	}
+	utils.PrintInfo(fmt.Sprintf("Derefter: %s", estimation.SpotPriceMethod))
+	utils.PrintBlankLine()
 
 	utils.PrintInfo("TOTALT:")
-	utils.PrintInfo(fmt.Sprintf("Samlet estimeret forbrug: %.2f kWh", estimation.TotalEstimatedkWh))
-	utils.PrintInfo(fmt.Sprintf("Total timer: %d", len(estimation.CombinedConsumption)))
+	utils.PrintInfo(fmt.Sprintf("Samlet forbrug: %.2f kWh (%.2f kWh målt + %.2f kWh estimeret)",
+		estimation.TotalEstimatedkWh, estimation.ActualTotalKWh, estimation.EstimatedTotalKWh))
+	utils.PrintInfo(fmt.Sprintf("Total timer: %d", estimation.ActualHours+estimation.EstimatedHours))
 	utils.PrintInfo(fmt.Sprintf("Metode: %s", estimation.EstimationMethod))
-	utils.PrintWarning("Note: Alt forbrug er estimeret baseret på årligt forbrug (aconto princip)")
-	fmt.Println()
+	if estimation.EstimatedHours > 0 {
+		utils.PrintWarning(fmt.Sprintf("Note: Forbrug fra %s er estimeret (%s)",
+			estimation.SplitDateTime.In(copenhagen).Format("2006-01-02 15:04"), estimation.ConsumptionDescription))
+	}
+	utils.PrintBlankLine()
 }
 
 // calculateAverageSpotPrice beregner gennemsnitlig spotpris fra en liste
@@ -0,0 +1,47 @@
+package billing_test
+
+import (
+	"context"
+	"electricity-invoice-calculator/lib/billing"
+	"electricity-invoice-calculator/lib/energinet"
+	"electricity-invoice-calculator/lib/fakeapi"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHistoricalSpotPricesDaylightSavingTime(t *testing.T) {
+	fixtures, err := fakeapi.DefaultFixtures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(fakeapi.NewHandler(fixtures))
+	defer server.Close()
+
+	client := energinet.NewClient()
+	client.BaseURL = fakeapi.EnerginetURL(server.URL)
+
+	// The 25-hour day of 2025 is priced from 26 October 2024, a day of 24 hours
+	hours := dayHours(time.Date(2025, time.October, 26, 0, 0, 0, 0, time.UTC))
+	spotPrices, err := billing.EstimateHistoricalSpotPricesForPeriod(context.Background(), client, hours[0], hours[0].AddDate(0, 0, 1), "DK2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spotPrices) != len(hours) {
+		t.Fatalf("%d estimated hours, want %d", len(spotPrices), len(hours))
+	}
+
+	index := billing.NewSpotPriceIndex(spotPrices)
+	first, _ := index.Lookup(hours[2])
+	second, _ := index.Lookup(hours[3])
+	if first == second {
+		t.Errorf("both 02:00 hours priced %.4f DKK/kWh, want the prices of 02:00 and 03:00 last year", first)
+	}
+
+	// The extra hour runs the day over by one; its last hour takes the price of 23:00 last year
+	last, _ := index.Lookup(hours[len(hours)-1])
+	beforeLast, _ := index.Lookup(hours[len(hours)-2])
+	if last != beforeLast {
+		t.Errorf("last hour priced %.4f DKK/kWh, want the %.4f DKK/kWh of 23:00 last year", last, beforeLast)
+	}
+}
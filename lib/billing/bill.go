@@ -0,0 +1,72 @@
+package billing
+
+import (
+	"electricity-invoice-calculator/lib/eloverblik"
+	"time"
+)
+
+// VATRate is the Danish VAT rate applied to the complete bill
+const VATRate = 0.25
+
+// BillSummary contains the totals of a calculated bill
+type BillSummary struct {
+	Period                string             `json:"period"`
+	PeriodStart           time.Time          `json:"periodStart"`
+	PeriodEnd             time.Time          `json:"periodEnd"`
+	PeriodType            PeriodType         `json:"periodType"`
+	TotalConsumptionKWh   float64            `json:"totalConsumptionKWh"`
+	MeteredKWh            float64            `json:"meteredKWh,omitempty"`   // hybrid: consumption read from the meter
+	EstimatedKWh          float64            `json:"estimatedKWh,omitempty"` // hybrid: estimated consumption of the remaining hours
+	TariffCosts           map[string]float64 `json:"tariffCosts"`            // tariff name -> cost in DKK
+	SupplierCost          float64            `json:"supplierCost"`           // electricity supplier cost in DKK
+	SpotCost              float64            `json:"spotCost"`               // spot price cost in DKK
+	TotalUsageCost        float64            `json:"totalUsageCost"`
+	Subscriptions         map[string]float64 `json:"subscriptions"` // subscription name -> cost for the period in DKK
+	TotalSubscriptionCost float64            `json:"totalSubscriptionCost"`
+	SubtotalExclVAT       float64            `json:"subtotalExclVAT"`
+	VAT                   float64            `json:"vat"`
+	TotalInclVAT          float64            `json:"totalInclVAT"`
+	AveragePricePerKWh    float64            `json:"averagePricePerKWh"` // incl. VAT
+
+	// Data problems found before pricing; in lenient mode the listed hours are interpolated
+	Completeness *CompletenessReport `json:"completeness,omitempty"`
+}
+
+// SummarizeBill combines the hourly costs and the subscriptions into the totals of a bill
+func SummarizeBill(period Period, periodType PeriodType, totalConsumption float64, hourlyTariffCosts []HourlyTariffCost, chargesData *eloverblik.ChargesResult) *BillSummary {
+	summary := &BillSummary{
+		Period:              period.Label,
+		PeriodStart:         period.Start,
+		PeriodEnd:           period.End,
+		PeriodType:          periodType,
+		TotalConsumptionKWh: totalConsumption,
+		TariffCosts:         SummarizeTariffCosts(hourlyTariffCosts),
+		SupplierCost:        GetTotalSupplierCosts(hourlyTariffCosts),
+		SpotCost:            GetTotalSpotCosts(hourlyTariffCosts),
+		TotalUsageCost:      GetTotalTariffCosts(hourlyTariffCosts),
+		Subscriptions:       make(map[string]float64),
+	}
+
+	// Subscriptions are priced per month
+	monthsInPeriod := 1.0
+	if period.Frequency == Quarterly {
+		monthsInPeriod = 3.0
+	}
+
+	for _, subscription := range chargesData.Subscriptions {
+		monthlyCost := subscription.Price * float64(subscription.Quantity)
+		periodCost := monthlyCost * monthsInPeriod
+		summary.Subscriptions[subscription.Name] = periodCost
+		summary.TotalSubscriptionCost += periodCost
+	}
+
+	summary.SubtotalExclVAT = summary.TotalUsageCost + summary.TotalSubscriptionCost
+	summary.VAT = summary.SubtotalExclVAT * VATRate
+	summary.TotalInclVAT = summary.SubtotalExclVAT + summary.VAT
+
+	if totalConsumption > 0 {
+		summary.AveragePricePerKWh = summary.TotalInclVAT / totalConsumption
+	}
+
+	return summary
+}
@@ -1,23 +1,29 @@
 package billing
 
 import (
+	"context"
 	"electricity-invoice-calculator/lib/eloverblik"
 	"electricity-invoice-calculator/lib/energinet"
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 	"time"
 )
 
-// HourlyTariffCost represents the cost breakdown for a single hour
+// HourlyTariffCost represents the cost breakdown for a single metering interval (an hour or a quarter-hour)
 type HourlyTariffCost struct {
-	DateTime     time.Time
+	DateTime     time.Time     // start of the interval
+	Resolution   time.Duration // length of the interval
 	Consumption  float64
 	TariffCosts  map[string]float64 // tariff name -> cost in DKK
 	SupplierCost float64            // electricity supplier cost in DKK
 	SpotPrice    float64            // spot price DKK/kWh
 	SpotCost     float64            // spot price cost in DKK
 	TotalCost    float64            // total of all tariffs + supplier cost + spot cost
+
+	ConsumptionInterpolated bool // consumption was missing and is interpolated
+	SpotPriceInterpolated   bool // spot price was missing and is interpolated
 }
 
 // GridCompany represents a grid company mapping
@@ -60,36 +66,43 @@ func FindPriceArea(gridAreaName string, mapping *GridCompaniesMapping) (string,
 	return "", fmt.Errorf("grid area ID %s not found in mapping", gridAreaName)
 }
 
-// GetSpotPriceForHour gets the spot price for a specific hour from spot price data
+// GetSpotPriceForHour gets the spot price for the interval starting at hourDateTime from spot price data.
+// It indexes spotPrices on every call; use a SpotPriceIndex to look up many intervals.
 func GetSpotPriceForHour(hourDateTime time.Time, spotPrices []energinet.SpotPriceRecord) (float64, error) {
-	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
-	localTime := hourDateTime.In(copenhagen)
+	return NewSpotPriceIndex(spotPrices).Price(hourDateTime)
+}
 
-	// Format to match the HourDK format in spot price data (YYYY-MM-DDTHH:00:00)
-	hourDK := localTime.Format("2006-01-02T15:04:05")
+// SelectValidTariffs returns the tariffs valid at the given time.
+// If several versions of a tariff (same name) are valid, the most recent version wins,
+// so a price change is applied from its validity date onwards.
+// It parses the validity dates on every call; use a TariffIndex to select for many intervals.
+func SelectValidTariffs(tariffs []eloverblik.Tariff, at time.Time) []eloverblik.Tariff {
+	return NewTariffIndex(tariffs).ValidAt(at)
+}
 
-	for _, record := range spotPrices {
-		if record.HourDK == hourDK {
-			// Convert from DKK/MWh to DKK/kWh
-			return energinet.ConvertToKWh(record.SpotPriceDKK), nil
-		}
-	}
+// Time zone of tariff hour positions, loaded once as it is needed for every hour
+var tariffLocation, _ = time.LoadLocation("Europe/Copenhagen")
 
-	return 0, fmt.Errorf("no spot price found for hour %s", hourDK)
+// TariffPosition returns the position (1-24) of the hourly tariff price that applies at t.
+// Positions follow the Copenhagen wall clock: on the 23-hour day in March position 3 is
+// skipped, and on the 25-hour day in October both 02:00 hours have position 3.
+func TariffPosition(t time.Time) string {
+	return strconv.Itoa(t.In(tariffLocation).Hour() + 1)
 }
 
 // CalculateHourlyTariffs calculates all tariff costs for a single hour of consumption
 // supplierPricePerKWh is the electricity supplier's price in DKK per kWh (e.g., 0.02 for 2 øre)
-// spotPrices contains the spot price data for the period
-func CalculateHourlyTariffs(hourlyConsumption eloverblik.HourlyConsumption, chargesData *eloverblik.ChargesResult, supplierPricePerKWh float64, spotPrices []energinet.SpotPriceRecord) HourlyTariffCost {
-	// Convert to Copenhagen time and get the hour position (1-24)
-	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
-	localTime := hourlyConsumption.DateTime.In(copenhagen)
-	hourPosition := localTime.Hour() + 1 // Convert 0-23 to 1-24 for tariff positions
+// tariffs contains the charges of the period, indexed with NewTariffIndex
+// spotPrices contains the spot price data for the period, indexed with NewSpotPriceIndex
+// An hour without a spot price is an error; see CheckCompleteness for finding them before pricing.
+func CalculateHourlyTariffs(hourlyConsumption eloverblik.HourlyConsumption, tariffs *TariffIndex, supplierPricePerKWh float64, spotPrices *SpotPriceIndex) (HourlyTariffCost, error) {
+	// Hourly tariffs apply to every quarter-hour within the hour
+	hourPosition := TariffPosition(hourlyConsumption.DateTime)
 
 	// Initialize result
 	result := HourlyTariffCost{
 		DateTime:     hourlyConsumption.DateTime,
+		Resolution:   hourlyConsumption.Resolution,
 		Consumption:  hourlyConsumption.Consumption,
 		TariffCosts:  make(map[string]float64),
 		SupplierCost: 0.0,
@@ -99,18 +112,16 @@ func CalculateHourlyTariffs(hourlyConsumption eloverblik.HourlyConsumption, char
 	}
 
 	// Get spot price for this hour
-	spotPrice, err := GetSpotPriceForHour(hourlyConsumption.DateTime, spotPrices)
+	spotPrice, err := spotPrices.Price(hourlyConsumption.DateTime)
 	if err != nil {
-		// If we can't find spot price, log but continue (maybe set to 0 or handle differently)
-		fmt.Printf("Warning: %v\n", err)
-		spotPrice = 0.0
+		return result, err
 	}
 	result.SpotPrice = spotPrice
 	result.SpotCost = hourlyConsumption.Consumption * spotPrice
 
-	// Calculate cost for each tariff
+	// Calculate cost for each tariff valid in this hour
 	var totalTariffCost float64
-	for _, tariff := range chargesData.Tariffs {
+	for _, tariff := range tariffs.ValidAt(hourlyConsumption.DateTime) {
 		var applicablePrice float64
 
 		if tariff.PeriodType == "P1D" && len(tariff.Prices) == 1 {
@@ -119,7 +130,7 @@ func CalculateHourlyTariffs(hourlyConsumption eloverblik.HourlyConsumption, char
 		} else if tariff.PeriodType == "PT1H" && len(tariff.Prices) == 24 {
 			// Hourly variable tariff (Nettarif C) - different price per hour
 			for _, price := range tariff.Prices {
-				if price.Position == fmt.Sprintf("%d", hourPosition) {
+				if price.Position == hourPosition {
 					applicablePrice = price.Price
 					break
 				}
@@ -131,7 +142,7 @@ func CalculateHourlyTariffs(hourlyConsumption eloverblik.HourlyConsumption, char
 			} else {
 				// Try to find matching position
 				for _, price := range tariff.Prices {
-					if price.Position == fmt.Sprintf("%d", hourPosition) {
+					if price.Position == hourPosition {
 						applicablePrice = price.Price
 						break
 					}
@@ -151,19 +162,24 @@ func CalculateHourlyTariffs(hourlyConsumption eloverblik.HourlyConsumption, char
 	// Calculate total cost (all tariffs + supplier cost + spot cost)
 	result.TotalCost = totalTariffCost + result.SupplierCost + result.SpotCost
 
-	return result
+	return result, nil
 }
 
 // CalculateAllHourlyTariffs calculates tariff costs for all hours in the consumption data
-func CalculateAllHourlyTariffs(consumptionData []eloverblik.HourlyConsumption, chargesData *eloverblik.ChargesResult, supplierPricePerKWh float64, spotPrices []energinet.SpotPriceRecord) []HourlyTariffCost {
-	var results []HourlyTariffCost
+func CalculateAllHourlyTariffs(consumptionData []eloverblik.HourlyConsumption, chargesData *eloverblik.ChargesResult, supplierPricePerKWh float64, spotPrices []energinet.SpotPriceRecord) ([]HourlyTariffCost, error) {
+	results := make([]HourlyTariffCost, 0, len(consumptionData))
+	spotPriceIndex := NewSpotPriceIndex(spotPrices)
+	tariffIndex := NewTariffIndex(chargesData.Tariffs)
 
 	for _, hourlyConsumption := range consumptionData {
-		hourlyCost := CalculateHourlyTariffs(hourlyConsumption, chargesData, supplierPricePerKWh, spotPrices)
+		hourlyCost, err := CalculateHourlyTariffs(hourlyConsumption, tariffIndex, supplierPricePerKWh, spotPriceIndex)
+		if err != nil {
+			return nil, err
+		}
 		results = append(results, hourlyCost)
 	}
 
-	return results
+	return results, nil
 }
 
 // SummarizeTariffCosts creates a summary of all tariff costs across all hours
@@ -212,14 +228,11 @@ func GetTotalSpotCosts(hourlyTariffCosts []HourlyTariffCost) float64 {
 	return total
 }
 
-// FetchSpotPricesForPeriod fetches spot prices for the given period and price area
-func FetchSpotPricesForPeriod(startDate, endDate time.Time, priceArea string) ([]energinet.SpotPriceRecord, error) {
-	// Format dates for the API
-	startDateStr := startDate.Format("2006-01-02")
-	endDateStr := endDate.Format("2006-01-02")
-
-	// Get spot prices from Energinet API
-	spotPrices, err := energinet.GetSpotPrices(startDateStr, endDateStr, []string{priceArea})
+// FetchSpotPricesForPeriod fetches spot prices for the given period and price area.
+// resolution is time.Hour for hourly consumption and 15 minutes for quarter-hour consumption;
+// see energinet.Client.GetSpotPricesForPeriod.
+func FetchSpotPricesForPeriod(ctx context.Context, client *energinet.Client, startDate, endDate time.Time, priceArea string, resolution time.Duration) ([]energinet.SpotPriceRecord, error) {
+	spotPrices, err := client.GetSpotPricesForPeriod(ctx, startDate, endDate, priceArea, resolution)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch spot prices: %v", err)
 	}
@@ -0,0 +1,207 @@
+package billing_test
+
+import (
+	"electricity-invoice-calculator/lib/billing"
+	"electricity-invoice-calculator/lib/eloverblik"
+	"electricity-invoice-calculator/lib/energinet"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// syntheticSeries returns hourly consumption and spot prices for every hour of [start, end).
+// Spot prices are sorted newest first, as returned by the API.
+func syntheticSeries(start, end time.Time) ([]eloverblik.HourlyConsumption, []energinet.SpotPriceRecord) {
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+
+	var consumption []eloverblik.HourlyConsumption
+	var spotPrices []energinet.SpotPriceRecord
+	for t := start; t.Before(end); t = t.Add(time.Hour) {
+		// Daily cycle with an evening peak
+		hour := float64(t.In(copenhagen).Hour())
+		price := 600 + 300*math.Sin((hour-6)*math.Pi/12)
+		consumption = append(consumption, eloverblik.HourlyConsumption{
+			DateTime:    t,
+			Resolution:  time.Hour,
+			Consumption: 0.3 + 0.2*math.Sin((hour-12)*math.Pi/12),
+			Quality:     "A04",
+		})
+		spotPrices = append(spotPrices, energinet.SpotPriceRecord{
+			HourUTC:      t.UTC().Format(energinet.RecordTimeLayout),
+			HourDK:       t.In(copenhagen).Format(energinet.RecordTimeLayout),
+			PriceArea:    "DK1",
+			SpotPriceDKK: price,
+			SpotPriceEUR: price / energinet.EURToDKK,
+		})
+	}
+
+	for i, j := 0, len(spotPrices)-1; i < j; i, j = i+1, j-1 {
+		spotPrices[i], spotPrices[j] = spotPrices[j], spotPrices[i]
+	}
+
+	return consumption, spotPrices
+}
+
+// syntheticCharges returns a typical set of tariffs: an hourly grid tariff with a price change
+// every year, and daily fixed tariffs
+func syntheticCharges() *eloverblik.ChargesResult {
+	charges := &eloverblik.ChargesResult{MeteringPointId: "571313100000000000"}
+
+	for year := 2024; year <= 2040; year++ {
+		var prices []eloverblik.Price
+		for position := 1; position <= 24; position++ {
+			price := 0.15
+			if position >= 18 && position <= 21 {
+				price = 0.45
+			}
+			prices = append(prices, eloverblik.Price{Position: strconv.Itoa(position), Price: price * (1 + float64(year-2024)/20)})
+		}
+		charges.Tariffs = append(charges.Tariffs, eloverblik.Tariff{
+			Prices:        prices,
+			Name:          "Nettarif C time",
+			ValidFromDate: fmt.Sprintf("%d-01-01T00:00:00", year),
+			PeriodType:    "PT1H",
+		})
+	}
+
+	for name, price := range map[string]float64{"Transmissions nettarif": 0.074, "Systemtarif": 0.051, "Elafgift": 0.761} {
+		charges.Tariffs = append(charges.Tariffs, eloverblik.Tariff{
+			Prices:        []eloverblik.Price{{Position: "1", Price: price}},
+			Name:          name,
+			ValidFromDate: "2024-01-01T00:00:00",
+			PeriodType:    "P1D",
+		})
+	}
+
+	return charges
+}
+
+// Days of the daylight saving time changes in 2025: 23 hours in March and 25 hours in October
+var dstDays = []struct {
+	name      string
+	date      time.Time
+	hours     int
+	positions []string
+}{
+	{"spring", time.Date(2025, time.March, 30, 0, 0, 0, 0, time.UTC), 23, []string{
+		"1", "2", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13",
+		"14", "15", "16", "17", "18", "19", "20", "21", "22", "23", "24",
+	}},
+	{"autumn", time.Date(2025, time.October, 26, 0, 0, 0, 0, time.UTC), 25, []string{
+		"1", "2", "3", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12",
+		"13", "14", "15", "16", "17", "18", "19", "20", "21", "22", "23", "24",
+	}},
+}
+
+// dayHours returns the start of every hour of a Copenhagen day
+func dayHours(date time.Time) []time.Time {
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, copenhagen)
+	end := start.AddDate(0, 0, 1)
+
+	var hours []time.Time
+	for t := start; t.Before(end); t = t.Add(time.Hour) {
+		hours = append(hours, t)
+	}
+	return hours
+}
+
+func TestTariffPositionDaylightSavingTime(t *testing.T) {
+	for _, day := range dstDays {
+		hours := dayHours(day.date)
+		if len(hours) != day.hours {
+			t.Fatalf("%s: %d hours, want %d", day.name, len(hours), day.hours)
+		}
+
+		var positions []string
+		for _, hour := range hours {
+			positions = append(positions, billing.TariffPosition(hour))
+		}
+		if !reflect.DeepEqual(positions, day.positions) {
+			t.Errorf("%s: positions %v, want %v", day.name, positions, day.positions)
+		}
+	}
+}
+
+func TestSpotPriceIndexDaylightSavingTime(t *testing.T) {
+	for _, day := range dstDays {
+		hours := dayHours(day.date)
+
+		// A different price for every hour, so the two 02:00 hours in October can be told apart
+		var records []energinet.SpotPriceRecord
+		for i, hour := range hours {
+			records = append(records, energinet.SpotPriceRecord{
+				HourUTC:      hour.UTC().Format(energinet.RecordTimeLayout),
+				HourDK:       hour.Format(energinet.RecordTimeLayout),
+				PriceArea:    "DK2",
+				SpotPriceDKK: float64(100 * (i + 1)),
+			})
+		}
+
+		index := billing.NewSpotPriceIndex(records)
+		if index.Len() != day.hours {
+			t.Errorf("%s: %d indexed hours, want %d", day.name, index.Len(), day.hours)
+		}
+		for i, hour := range hours {
+			price, err := index.Price(hour)
+			if err != nil {
+				t.Errorf("%s: %v", day.name, err)
+				continue
+			}
+			if want := float64(i+1) / 10; math.Abs(price-want) > 1e-9 {
+				t.Errorf("%s: hour %s priced %.2f DKK/kWh, want %.2f DKK/kWh", day.name, hour.Format(time.RFC3339), price, want)
+			}
+		}
+	}
+}
+
+func TestConsumptionByHourDaylightSavingTime(t *testing.T) {
+	for _, day := range dstDays {
+		var consumption []eloverblik.HourlyConsumption
+		for _, hour := range dayHours(day.date) {
+			consumption = append(consumption, eloverblik.HourlyConsumption{DateTime: hour, Resolution: time.Hour, Consumption: 1})
+		}
+
+		byHour := eloverblik.GetConsumptionByHour(consumption)
+		total := 0
+		for hour := 0; hour < 24; hour++ {
+			want := 1
+			if hour == 2 {
+				// No 02:00 hour in March and two of them in October
+				want = day.hours - 23
+			}
+			if len(byHour[hour]) != want {
+				t.Errorf("%s: %d values for hour %02d, want %d", day.name, len(byHour[hour]), hour, want)
+			}
+			total += len(byHour[hour])
+		}
+		if total != day.hours {
+			t.Errorf("%s: %d hours in total, want %d", day.name, total, day.hours)
+		}
+	}
+}
+
+func BenchmarkCalculateAllHourlyTariffs(b *testing.B) {
+	charges := syntheticCharges()
+
+	for _, years := range []int{1, 5} {
+		b.Run(fmt.Sprintf("%dy", years), func(b *testing.B) {
+			start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			consumption, spotPrices := syntheticSeries(start, start.AddDate(years, 0, 0))
+
+			// Every hour must be priced, or the benchmark would time an early error
+			if _, err := billing.CalculateAllHourlyTariffs(consumption, charges, 0.02, spotPrices); err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				billing.CalculateAllHourlyTariffs(consumption, charges, 0.02, spotPrices)
+			}
+		})
+	}
+}
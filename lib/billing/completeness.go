@@ -0,0 +1,316 @@
+package billing
+
+import (
+	"electricity-invoice-calculator/lib/eloverblik"
+	"electricity-invoice-calculator/lib/energinet"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CompletenessMode decides how a bill is calculated from incomplete data
+type CompletenessMode string
+
+const (
+	CompletenessStrict  CompletenessMode = "strict"  // abort the calculation
+	CompletenessLenient CompletenessMode = "lenient" // interpolate missing hours and flag them
+)
+
+// Converts a string to CompletenessMode
+func ParseCompletenessMode(value string) (CompletenessMode, error) {
+	switch CompletenessMode(value) {
+	case CompletenessStrict, CompletenessLenient:
+		return CompletenessMode(value), nil
+	default:
+		return "", fmt.Errorf("invalid completeness mode %q (expected strict or lenient)", value)
+	}
+}
+
+// CompletenessReport lists the hours of a period with missing or duplicated data.
+// Hours are the start of the hour.
+type CompletenessReport struct {
+	MissingConsumption   []time.Time `json:"missingConsumptionHours,omitempty"`
+	DuplicateConsumption []time.Time `json:"duplicateConsumptionHours,omitempty"`
+	MissingSpotPrices    []time.Time `json:"missingSpotPriceHours,omitempty"`
+	DuplicateSpotPrices  []time.Time `json:"duplicateSpotPriceHours,omitempty"`
+}
+
+// CheckCompleteness checks consumption and spot prices of [start, end) before pricing.
+// An hour misses consumption if its intervals do not cover the whole hour, and misses
+// a spot price if one of its intervals (or the hour itself, without consumption) has no price.
+func CheckCompleteness(consumption []eloverblik.HourlyConsumption, spotPrices []energinet.SpotPriceRecord, start, end time.Time) CompletenessReport {
+	var report CompletenessReport
+
+	// Consumption: covered length and duplicated intervals per hour
+	seenIntervals := make(map[int64]bool)
+	coveredByHour := make(map[int64]time.Duration)
+	intervalsByHour := make(map[int64][]time.Time)
+	duplicateConsumption := make(map[int64]bool)
+	for _, interval := range consumption {
+		hour := interval.DateTime.Truncate(time.Hour).Unix()
+		if seenIntervals[interval.DateTime.Unix()] {
+			duplicateConsumption[hour] = true
+			continue
+		}
+		seenIntervals[interval.DateTime.Unix()] = true
+		coveredByHour[hour] += interval.Resolution
+		intervalsByHour[hour] = append(intervalsByHour[hour], interval.DateTime)
+	}
+
+	// Spot prices: available intervals and duplicates
+	priced := make(map[int64]bool)
+	duplicateSpotPrices := make(map[int64]bool)
+	for _, record := range spotPrices {
+		recordTime, err := time.ParseInLocation(energinet.RecordTimeLayout, record.HourUTC, time.UTC)
+		if err != nil {
+			continue
+		}
+		if priced[recordTime.Unix()] {
+			duplicateSpotPrices[recordTime.Truncate(time.Hour).Unix()] = true
+		}
+		priced[recordTime.Unix()] = true
+	}
+
+	for hour := start.Truncate(time.Hour); hour.Before(end); hour = hour.Add(time.Hour) {
+		key := hour.Unix()
+
+		if coveredByHour[key] < time.Hour {
+			report.MissingConsumption = append(report.MissingConsumption, hour)
+		}
+		if duplicateConsumption[key] {
+			report.DuplicateConsumption = append(report.DuplicateConsumption, hour)
+		}
+		if duplicateSpotPrices[key] {
+			report.DuplicateSpotPrices = append(report.DuplicateSpotPrices, hour)
+		}
+
+		// Every interval needs its own price or the price of its hour
+		intervals := intervalsByHour[key]
+		if len(intervals) == 0 {
+			intervals = []time.Time{hour}
+		}
+		for _, interval := range intervals {
+			if !priced[interval.Unix()] && !priced[key] {
+				report.MissingSpotPrices = append(report.MissingSpotPrices, hour)
+				break
+			}
+		}
+	}
+
+	return report
+}
+
+// IsComplete reports whether no data is missing or duplicated
+func (r CompletenessReport) IsComplete() bool {
+	return len(r.MissingConsumption) == 0 && len(r.DuplicateConsumption) == 0 &&
+		len(r.MissingSpotPrices) == 0 && len(r.DuplicateSpotPrices) == 0
+}
+
+// Lines describes every problem on one line, with the affected hours as ranges
+func (r CompletenessReport) Lines() []string {
+	var lines []string
+	add := func(problem string, hours []time.Time) {
+		if len(hours) > 0 {
+			lines = append(lines, fmt.Sprintf("%s: %d hours (%s)", problem, len(hours), formatHourRanges(hours)))
+		}
+	}
+
+	add("Missing consumption", r.MissingConsumption)
+	add("Duplicated consumption", r.DuplicateConsumption)
+	add("Missing spot prices", r.MissingSpotPrices)
+	add("Duplicated spot prices", r.DuplicateSpotPrices)
+	return lines
+}
+
+// RepairData removes duplicates and interpolates the missing hours of a report.
+// Missing consumption is interpolated linearly between the nearest complete hours and
+// split into intervals of the resolution of the data; missing spot prices are interpolated
+// linearly between the nearest prices. Interpolated consumption has the quality "INTERPOLATED".
+func RepairData(consumption []eloverblik.HourlyConsumption, spotPrices []energinet.SpotPriceRecord, report CompletenessReport) ([]eloverblik.HourlyConsumption, []energinet.SpotPriceRecord) {
+	repairedConsumption := repairConsumption(consumption, report.MissingConsumption)
+	return repairedConsumption, repairSpotPrices(spotPrices, repairedConsumption, report.MissingSpotPrices)
+}
+
+// repairConsumption removes duplicated intervals and replaces missing hours by interpolated ones
+func repairConsumption(consumption []eloverblik.HourlyConsumption, missingHours []time.Time) []eloverblik.HourlyConsumption {
+	missing := make(map[int64]bool)
+	for _, hour := range missingHours {
+		missing[hour.Unix()] = true
+	}
+
+	// Keep the first of duplicated intervals and drop partial data of missing hours
+	seen := make(map[int64]bool)
+	var repaired []eloverblik.HourlyConsumption
+	for _, interval := range consumption {
+		if seen[interval.DateTime.Unix()] || missing[interval.DateTime.Truncate(time.Hour).Unix()] {
+			continue
+		}
+		seen[interval.DateTime.Unix()] = true
+		repaired = append(repaired, interval)
+	}
+
+	// Known hourly totals to interpolate between
+	hourly := eloverblik.SumByHour(repaired)
+	sort.Slice(hourly, func(i, j int) bool { return hourly[i].DateTime.Before(hourly[j].DateTime) })
+	resolution := eloverblik.FinestResolution(repaired)
+
+	for _, hour := range missingHours {
+		total, ok := interpolate(hour, len(hourly), func(i int) (time.Time, float64) {
+			return hourly[i].DateTime, hourly[i].Consumption
+		})
+		if !ok {
+			continue
+		}
+
+		intervals := int(time.Hour / resolution)
+		for i := 0; i < intervals; i++ {
+			repaired = append(repaired, eloverblik.HourlyConsumption{
+				DateTime:    hour.Add(time.Duration(i) * resolution).UTC(),
+				Resolution:  resolution,
+				Consumption: total / float64(intervals),
+				Quality:     "INTERPOLATED",
+			})
+		}
+	}
+
+	sort.SliceStable(repaired, func(i, j int) bool { return repaired[i].DateTime.Before(repaired[j].DateTime) })
+	return repaired
+}
+
+// repairSpotPrices removes duplicated prices and adds interpolated prices for the intervals
+// of consumption in the missing hours (the whole hour if it has no consumption)
+func repairSpotPrices(spotPrices []energinet.SpotPriceRecord, consumption []eloverblik.HourlyConsumption, missingHours []time.Time) []energinet.SpotPriceRecord {
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+
+	type pricePoint struct {
+		time     time.Time
+		price    float64
+		priceEUR float64
+	}
+
+	seen := make(map[string]bool)
+	var repaired []energinet.SpotPriceRecord
+	var known []pricePoint
+	for _, record := range spotPrices {
+		if seen[record.HourUTC] {
+			continue
+		}
+		seen[record.HourUTC] = true
+		repaired = append(repaired, record)
+
+		if recordTime, err := time.ParseInLocation(energinet.RecordTimeLayout, record.HourUTC, time.UTC); err == nil {
+			known = append(known, pricePoint{recordTime, record.SpotPriceDKK, record.SpotPriceEUR})
+		}
+	}
+	sort.Slice(known, func(i, j int) bool { return known[i].time.Before(known[j].time) })
+
+	priceArea := ""
+	if len(spotPrices) > 0 {
+		priceArea = spotPrices[0].PriceArea
+	}
+
+	intervalsByHour := make(map[int64][]time.Time)
+	for _, interval := range consumption {
+		hour := interval.DateTime.Truncate(time.Hour).Unix()
+		intervalsByHour[hour] = append(intervalsByHour[hour], interval.DateTime)
+	}
+
+	for _, hour := range missingHours {
+		intervals := intervalsByHour[hour.Unix()]
+		if len(intervals) == 0 {
+			intervals = []time.Time{hour}
+		}
+
+		for _, interval := range intervals {
+			key := interval.UTC().Format(energinet.RecordTimeLayout)
+			if seen[key] || seen[hour.UTC().Format(energinet.RecordTimeLayout)] {
+				continue
+			}
+
+			price, ok := interpolate(interval, len(known), func(i int) (time.Time, float64) {
+				return known[i].time, known[i].price
+			})
+			if !ok {
+				continue
+			}
+			// The EUR price comes from the real records as well, at the rate of that day
+			priceEUR, _ := interpolate(interval, len(known), func(i int) (time.Time, float64) {
+				return known[i].time, known[i].priceEUR
+			})
+
+			seen[key] = true
+			repaired = append(repaired, energinet.SpotPriceRecord{
+				HourUTC:      key,
+				HourDK:       interval.In(copenhagen).Format(energinet.RecordTimeLayout),
+				PriceArea:    priceArea,
+				SpotPriceDKK: price,
+				SpotPriceEUR: priceEUR,
+			})
+		}
+	}
+
+	return repaired
+}
+
+// interpolate estimates the value at t linearly between the nearest points before and after it.
+// Points must be sorted by time. Before the first or after the last point the nearest value is used.
+func interpolate(t time.Time, n int, point func(i int) (time.Time, float64)) (float64, bool) {
+	if n == 0 {
+		return 0, false
+	}
+
+	after := sort.Search(n, func(i int) bool {
+		pointTime, _ := point(i)
+		return pointTime.After(t)
+	})
+
+	if after == 0 {
+		_, value := point(0)
+		return value, true
+	}
+	beforeTime, beforeValue := point(after - 1)
+	if after == n {
+		return beforeValue, true
+	}
+	afterTime, afterValue := point(after)
+
+	fraction := float64(t.Sub(beforeTime)) / float64(afterTime.Sub(beforeTime))
+	return beforeValue + fraction*(afterValue-beforeValue), true
+}
+
+// MarkInterpolated flags the costs of the hours that were interpolated by RepairData
+func MarkInterpolated(costs []HourlyTariffCost, report CompletenessReport) {
+	consumptionHours := make(map[int64]bool)
+	for _, hour := range report.MissingConsumption {
+		consumptionHours[hour.Unix()] = true
+	}
+	spotHours := make(map[int64]bool)
+	for _, hour := range report.MissingSpotPrices {
+		spotHours[hour.Unix()] = true
+	}
+
+	for i := range costs {
+		hour := costs[i].DateTime.Truncate(time.Hour).Unix()
+		costs[i].ConsumptionInterpolated = consumptionHours[hour]
+		costs[i].SpotPriceInterpolated = spotHours[hour]
+	}
+}
+
+// formatHourRanges shows runs of consecutive hours as Copenhagen time ranges
+func formatHourRanges(hours []time.Time) string {
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+
+	var ranges []string
+	for i := 0; i < len(hours); {
+		j := i + 1
+		for j < len(hours) && hours[j].Equal(hours[j-1].Add(time.Hour)) {
+			j++
+		}
+		ranges = append(ranges, fmt.Sprintf("%s to %s",
+			hours[i].In(copenhagen).Format("2006-01-02 15:04"),
+			hours[j-1].Add(time.Hour).In(copenhagen).Format("2006-01-02 15:04")))
+		i = j
+	}
+	return strings.Join(ranges, ", ")
+}
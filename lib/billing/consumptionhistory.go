@@ -0,0 +1,154 @@
+package billing
+
+import (
+	"context"
+	"electricity-invoice-calculator/lib/eloverblik"
+	"electricity-invoice-calculator/lib/utils"
+	"fmt"
+	"time"
+)
+
+// Months of metered consumption the history method downloads, and the minimum it needs
+const (
+	historyMonths        = 24
+	minimumHistoryMonths = 12
+)
+
+// ConsumptionHistoryProfile is the average metered consumption per calendar month and
+// Copenhagen hour of day, built from the meter point's own history
+type ConsumptionHistoryProfile struct {
+	Average [12][24]float64 // kWh per hour, [month-1][hour of day]
+	From    time.Time       // start of the first metered hour used
+	To      time.Time       // end of the last metered hour used
+	Hours   int             // metered hours used
+}
+
+// FetchConsumptionHistory downloads up to 24 months of hourly consumption of the meter point
+// before the given time, but not before since (the consumer start date, zero if unknown).
+// The months are requested one year at a time, newest first. If an older year cannot be
+// downloaded, the newer years are returned as long as they cover minimumHistoryMonths.
+func FetchConsumptionHistory(ctx context.Context, client *eloverblik.Client, meterPointId string, since, before time.Time) ([]eloverblik.HourlyConsumption, error) {
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+	local := before.In(copenhagen)
+	end := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, copenhagen)
+
+	start := end.AddDate(0, -historyMonths, 0)
+	if since.After(start) {
+		start = since
+	}
+
+	var history []eloverblik.HourlyConsumption
+	for yearEnd := end; yearEnd.After(start); yearEnd = yearEnd.AddDate(-1, 0, 0) {
+		yearStart := yearEnd.AddDate(-1, 0, 0)
+		if yearStart.Before(start) {
+			yearStart = start
+		}
+
+		year, err := client.GetConsumptionForPeriod(ctx, meterPointId, yearStart, yearEnd, eloverblik.AggregationHour)
+		if err != nil {
+			if ctx.Err() != nil || yearEnd.After(end.AddDate(0, -minimumHistoryMonths, 0)) {
+				return nil, err
+			}
+			utils.PrintWarning(fmt.Sprintf("Could not fetch consumption before %s, using the %d months after it: %v",
+				yearEnd.Format("2006-01-02"), minimumHistoryMonths, err))
+			break
+		}
+		history = append(year, history...)
+	}
+
+	return history, nil
+}
+
+// BuildConsumptionHistoryProfile averages metered consumption per calendar month and hour of day.
+// At least 12 different calendar months must have data for the profile to cover a whole year.
+func BuildConsumptionHistoryProfile(history []eloverblik.HourlyConsumption) (*ConsumptionHistoryProfile, error) {
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+
+	var profile ConsumptionHistoryProfile
+	var totals [12][24]float64
+	var counts [12][24]int
+
+	for _, hourly := range eloverblik.SumByHour(history) {
+		local := hourly.DateTime.In(copenhagen)
+		totals[local.Month()-1][local.Hour()] += hourly.Consumption
+		counts[local.Month()-1][local.Hour()]++
+
+		if profile.Hours == 0 || hourly.DateTime.Before(profile.From) {
+			profile.From = hourly.DateTime
+		}
+		if hourEnd := hourly.DateTime.Add(time.Hour); hourEnd.After(profile.To) {
+			profile.To = hourEnd
+		}
+		profile.Hours++
+	}
+
+	monthsCovered := 0
+	for month := range counts {
+		if counts[month][0] > 0 {
+			monthsCovered++
+		}
+		for hour := range counts[month] {
+			if counts[month][hour] > 0 {
+				profile.Average[month][hour] = totals[month][hour] / float64(counts[month][hour])
+			}
+		}
+	}
+	if monthsCovered < minimumHistoryMonths {
+		return nil, fmt.Errorf("metered consumption covers only %d of 12 calendar months", monthsCovered)
+	}
+
+	return &profile, nil
+}
+
+// EstimateHistoryConsumptionForPeriod creates estimated hourly consumption data for
+// [startDate, endDate) from the average of the same month and hour of day in the profile
+func EstimateHistoryConsumptionForPeriod(profile *ConsumptionHistoryProfile, startDate, endDate time.Time) []eloverblik.HourlyConsumption {
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+
+	var estimatedConsumption []eloverblik.HourlyConsumption
+
+	currentTime := startDate
+	for currentTime.Before(endDate) {
+		local := currentTime.In(copenhagen)
+		estimatedConsumption = append(estimatedConsumption, eloverblik.HourlyConsumption{
+			DateTime:    currentTime,
+			Resolution:  time.Hour,
+			Consumption: profile.Average[local.Month()-1][local.Hour()],
+			Quality:     "ESTIMATED",
+		})
+		currentTime = currentTime.Add(1 * time.Hour)
+	}
+
+	return estimatedConsumption
+}
+
+// estimateHistoryConsumption downloads the history of the meter point since consumerStartDate
+// and estimates [startDate, endDate) from it
+func estimateHistoryConsumption(ctx context.Context, client *eloverblik.Client, meterPointId string, consumerStartDate, startDate, endDate time.Time) ([]eloverblik.HourlyConsumption, string, error) {
+	if client == nil || meterPointId == "" {
+		return nil, "", fmt.Errorf("no meter point to read the history of")
+	}
+
+	before := time.Now()
+	if startDate.Before(before) {
+		before = startDate
+	}
+
+	history, err := FetchConsumptionHistory(ctx, client, meterPointId, consumerStartDate, before)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not fetch consumption history: %v", err)
+	}
+
+	profile, err := BuildConsumptionHistoryProfile(history)
+	if err != nil {
+		return nil, "", err
+	}
+
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+	description := fmt.Sprintf("Meter history profile (%d hours metered %s to %s)",
+		profile.Hours,
+		profile.From.In(copenhagen).Format("2006-01-02"),
+		profile.To.Add(-time.Hour).In(copenhagen).Format("2006-01-02"))
+
+	return EstimateHistoryConsumptionForPeriod(profile, startDate, endDate), description, nil
+}
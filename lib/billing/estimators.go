@@ -0,0 +1,207 @@
+package billing
+
+import (
+	"context"
+	"electricity-invoice-calculator/lib/eloverblik"
+	"electricity-invoice-calculator/lib/energinet"
+	"electricity-invoice-calculator/lib/utils"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EstimationRequest describes the period to estimate and the data estimators may use
+type EstimationRequest struct {
+	Start                 time.Time // inclusive
+	End                   time.Time // exclusive
+	Frequency             BillingFrequency
+	EstimatedAnnualVolume int // kWh, from the meter point details
+	PriceArea             string
+	MeterPointId          string
+	ConsumerStartDate     time.Time // start of supply to the current consumer, zero if unknown
+	Eloverblik            *eloverblik.Client
+	Energinet             *energinet.Client
+
+	// Metered consumption imported from a file, used by hybrid periods instead of
+	// the consumption in Eloverblik; nil to fetch it
+	MeteredConsumption []eloverblik.HourlyConsumption
+}
+
+// ConsumptionEstimator estimates the consumption of every hour of a request.
+// The returned description of the method is shown in the estimation summary.
+type ConsumptionEstimator interface {
+	Name() string
+	EstimateConsumption(ctx context.Context, request EstimationRequest) ([]eloverblik.HourlyConsumption, string, error)
+}
+
+// SpotPriceEstimator estimates the spot price of every hour of a request.
+// The returned description of the method is shown in the estimation summary.
+type SpotPriceEstimator interface {
+	Name() string
+	EstimateSpotPrices(ctx context.Context, request EstimationRequest) ([]energinet.SpotPriceRecord, string, error)
+}
+
+// Registered estimators by name
+var (
+	estimatorsMutex       sync.RWMutex
+	consumptionEstimators = make(map[string]ConsumptionEstimator)
+	spotPriceEstimators   = make(map[string]SpotPriceEstimator)
+)
+
+// RegisterConsumptionEstimator makes a consumption estimator selectable by its name.
+// It panics if the name is empty or already registered.
+func RegisterConsumptionEstimator(estimator ConsumptionEstimator) {
+	estimatorsMutex.Lock()
+	defer estimatorsMutex.Unlock()
+
+	name := estimator.Name()
+	if _, exists := consumptionEstimators[name]; exists || name == "" {
+		panic(fmt.Sprintf("billing: consumption estimator %q registered twice or without a name", name))
+	}
+	consumptionEstimators[name] = estimator
+}
+
+// RegisterSpotPriceEstimator makes a spot price estimator selectable by its name.
+// It panics if the name is empty or already registered.
+func RegisterSpotPriceEstimator(estimator SpotPriceEstimator) {
+	estimatorsMutex.Lock()
+	defer estimatorsMutex.Unlock()
+
+	name := estimator.Name()
+	if _, exists := spotPriceEstimators[name]; exists || name == "" {
+		panic(fmt.Sprintf("billing: spot price estimator %q registered twice or without a name", name))
+	}
+	spotPriceEstimators[name] = estimator
+}
+
+// LookupConsumptionEstimator returns the consumption estimator registered under name
+func LookupConsumptionEstimator(name string) (ConsumptionEstimator, error) {
+	estimatorsMutex.RLock()
+	defer estimatorsMutex.RUnlock()
+
+	if estimator, exists := consumptionEstimators[name]; exists {
+		return estimator, nil
+	}
+	return nil, fmt.Errorf("unknown consumption estimator %q (expected %s)", name, strings.Join(sortedNames(consumptionEstimators), ", "))
+}
+
+// LookupSpotPriceEstimator returns the spot price estimator registered under name
+func LookupSpotPriceEstimator(name string) (SpotPriceEstimator, error) {
+	estimatorsMutex.RLock()
+	defer estimatorsMutex.RUnlock()
+
+	if estimator, exists := spotPriceEstimators[name]; exists {
+		return estimator, nil
+	}
+	return nil, fmt.Errorf("unknown spot price estimator %q (expected %s)", name, strings.Join(sortedNames(spotPriceEstimators), ", "))
+}
+
+// ConsumptionEstimatorNames returns the names of the registered consumption estimators, sorted
+func ConsumptionEstimatorNames() []string {
+	estimatorsMutex.RLock()
+	defer estimatorsMutex.RUnlock()
+	return sortedNames(consumptionEstimators)
+}
+
+// SpotPriceEstimatorNames returns the names of the registered spot price estimators, sorted
+func SpotPriceEstimatorNames() []string {
+	estimatorsMutex.RLock()
+	defer estimatorsMutex.RUnlock()
+	return sortedNames(spotPriceEstimators)
+}
+
+// sortedNames returns the keys of a registry in alphabetical order
+func sortedNames[T any](registry map[string]T) []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// The built-in estimators
+func init() {
+	RegisterConsumptionEstimator(FlatConsumption{})
+	RegisterConsumptionEstimator(ProfileConsumption{})
+	RegisterConsumptionEstimator(HistoryConsumption{})
+	RegisterSpotPriceEstimator(FixedSpotPrice{PriceDKKPerKWh: DefaultFixedSpotPrice})
+	RegisterSpotPriceEstimator(LastYearSpotPrices{})
+}
+
+// FlatConsumption spreads 1/12 (monthly) or 1/4 (quarterly) of the annual volume evenly over the hours
+type FlatConsumption struct{}
+
+func (FlatConsumption) Name() string { return "flat" }
+
+func (FlatConsumption) EstimateConsumption(ctx context.Context, request EstimationRequest) ([]eloverblik.HourlyConsumption, string, error) {
+	consumption, err := EstimateConsumptionForPeriod(request.EstimatedAnnualVolume, request.Start, request.End, request.Frequency)
+	return consumption, "Even spread of the annual volume", err
+}
+
+// ProfileConsumption shapes the annual volume by a Danish residential load profile
+type ProfileConsumption struct{}
+
+func (ProfileConsumption) Name() string { return "profile" }
+
+func (ProfileConsumption) EstimateConsumption(ctx context.Context, request EstimationRequest) ([]eloverblik.HourlyConsumption, string, error) {
+	consumption, err := EstimateProfileConsumptionForPeriod(request.EstimatedAnnualVolume, request.Start, request.End)
+	return consumption, "Seasonal residential load profile", err
+}
+
+// HistoryConsumption repeats the meter point's own consumption of the last 12-24 months.
+// If the history cannot be used it falls back to FlatConsumption and says why.
+type HistoryConsumption struct{}
+
+func (HistoryConsumption) Name() string { return "history" }
+
+func (HistoryConsumption) EstimateConsumption(ctx context.Context, request EstimationRequest) ([]eloverblik.HourlyConsumption, string, error) {
+	consumption, description, err := estimateHistoryConsumption(ctx, request.Eloverblik, request.MeterPointId, request.ConsumerStartDate, request.Start, request.End)
+	if err == nil {
+		return consumption, description, nil
+	}
+	if ctx.Err() != nil {
+		return nil, "", err
+	}
+
+	utils.PrintWarning(fmt.Sprintf("Meter history cannot be used (%v), falling back to the flat estimator", err))
+	consumption, flatErr := EstimateConsumptionForPeriod(request.EstimatedAnnualVolume, request.Start, request.End, request.Frequency)
+	return consumption, fmt.Sprintf("Even spread of the annual volume (meter history unavailable: %v)", err), flatErr
+}
+
+// Spot price in DKK/kWh of the built-in "fixed" estimator
+const DefaultFixedSpotPrice = 0.614029
+
+// FixedSpotPrice prices every hour at the same spot price
+type FixedSpotPrice struct {
+	PriceDKKPerKWh float64
+}
+
+func (FixedSpotPrice) Name() string { return "fixed" }
+
+func (f FixedSpotPrice) EstimateSpotPrices(ctx context.Context, request EstimationRequest) ([]energinet.SpotPriceRecord, string, error) {
+	spotPrices := generateFixedSpotPricesForPeriod(request.Start, request.End, request.PriceArea, f.PriceDKKPerKWh)
+	return spotPrices, fmt.Sprintf("Fixed spot price estimate (%.3f DKK/kWh)", f.PriceDKKPerKWh), nil
+}
+
+// LastYearSpotPrices uses the actual spot prices of the same hours last year.
+// If they cannot be fetched it falls back to the default fixed price.
+type LastYearSpotPrices struct{}
+
+func (LastYearSpotPrices) Name() string { return "historical" }
+
+func (LastYearSpotPrices) EstimateSpotPrices(ctx context.Context, request EstimationRequest) ([]energinet.SpotPriceRecord, string, error) {
+	spotPrices, err := EstimateHistoricalSpotPricesForPeriod(ctx, request.Energinet, request.Start, request.End, request.PriceArea)
+	if err == nil {
+		return spotPrices, "Historical spot prices from same period last year", nil
+	}
+	if ctx.Err() != nil {
+		return nil, "", err
+	}
+
+	// Fallback to fixed estimate if historical data fails
+	spotPrices = generateFixedSpotPricesForPeriod(request.Start, request.End, request.PriceArea, DefaultFixedSpotPrice)
+	return spotPrices, "Fixed spot price estimate (historical data unavailable)", nil
+}
@@ -0,0 +1,102 @@
+package billing
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WriteHourlyCSV writes one CSV row per hour with one column per tariff.
+// Times are written in Copenhagen local time including the UTC offset,
+// so the repeated hour when daylight saving time ends stays unambiguous.
+// The interpolated column names the interpolated data of the hour, if any.
+func WriteHourlyCSV(w io.Writer, hourlyTariffCosts []HourlyTariffCost) error {
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+
+	// Collect tariff names across all hours so every row has the same columns
+	tariffSet := make(map[string]bool)
+	for _, hourlyCost := range hourlyTariffCosts {
+		for name := range hourlyCost.TariffCosts {
+			tariffSet[name] = true
+		}
+	}
+	tariffNames := make([]string, 0, len(tariffSet))
+	for name := range tariffSet {
+		tariffNames = append(tariffNames, name)
+	}
+	sort.Strings(tariffNames)
+
+	writer := csv.NewWriter(w)
+
+	header := []string{"time", "consumption_kwh", "spot_price_dkk_per_kwh", "spot_cost_dkk"}
+	header = append(header, tariffNames...)
+	header = append(header, "supplier_cost_dkk", "total_cost_dkk", "interpolated")
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("could not write CSV header: %v", err)
+	}
+
+	for _, hourlyCost := range hourlyTariffCosts {
+		row := []string{
+			hourlyCost.DateTime.In(copenhagen).Format(time.RFC3339),
+			formatCSVFloat(hourlyCost.Consumption),
+			formatCSVFloat(hourlyCost.SpotPrice),
+			formatCSVFloat(hourlyCost.SpotCost),
+		}
+		for _, name := range tariffNames {
+			row = append(row, formatCSVFloat(hourlyCost.TariffCosts[name]))
+		}
+		row = append(row, formatCSVFloat(hourlyCost.SupplierCost), formatCSVFloat(hourlyCost.TotalCost), interpolatedColumn(hourlyCost))
+
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("could not write CSV row: %v", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("could not write CSV: %v", err)
+	}
+
+	return nil
+}
+
+// ExportHourlyCSV writes the hourly breakdown to a CSV file
+func ExportHourlyCSV(filename string, hourlyTariffCosts []HourlyTariffCost) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("could not create %s: %v", filename, err)
+	}
+
+	if err := WriteHourlyCSV(file, hourlyTariffCosts); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("could not close %s: %v", filename, err)
+	}
+
+	return nil
+}
+
+// interpolatedColumn names the interpolated data of an hour: consumption, spot_price or both
+func interpolatedColumn(hourlyCost HourlyTariffCost) string {
+	var interpolated []string
+	if hourlyCost.ConsumptionInterpolated {
+		interpolated = append(interpolated, "consumption")
+	}
+	if hourlyCost.SpotPriceInterpolated {
+		interpolated = append(interpolated, "spot_price")
+	}
+	return strings.Join(interpolated, "+")
+}
+
+// formatCSVFloat formats a number with six decimals, a decimal point and no exponent
+func formatCSVFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', 6, 64)
+}
@@ -0,0 +1,268 @@
+package billing
+
+import (
+	"bytes"
+	"context"
+	"electricity-invoice-calculator/lib/energinet"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ForwardPrice is the expected average (base load) spot price of a month or quarter in a price area
+type ForwardPrice struct {
+	Area   string  `json:"area"`   // DK1 or DK2
+	Period string  `json:"period"` // month (2027-01) or quarter (2027-Q1)
+	Price  float64 `json:"price"`
+	Unit   string  `json:"unit,omitempty"` // DKK/MWh (default) or EUR/MWh
+}
+
+// ForwardCurve holds forward prices in DKK/MWh by price area and period
+type ForwardCurve struct {
+	Source string                        // file the curve was loaded from
+	prices map[string]map[string]float64 // area -> period -> DKK/MWh
+}
+
+// Relative spot price per Copenhagen hour of day (00-01 first) on working days:
+// cheap at night, a morning peak, lower prices around noon from solar production and
+// the evening peak from 17 to 20. Every day is scaled to average the forward price.
+var intradayPriceWeights = [24]float64{
+	0.85, 0.80, 0.78, 0.77, 0.79, 0.86, 0.98, 1.12,
+	1.15, 1.05, 0.97, 0.92, 0.88, 0.87, 0.92, 1.00,
+	1.10, 1.25, 1.30, 1.22, 1.10, 1.02, 0.96, 0.90,
+}
+
+// Weekends follow the same shape with smaller swings
+const weekendPriceSwing = 0.6
+
+// LoadForwardCurve reads a forward price curve from a JSON file (a list of ForwardPrice)
+// or a CSV file with the columns area, period, price and optionally unit
+func LoadForwardCurve(filename string) (*ForwardCurve, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("could not read forward curve: %v", err)
+	}
+
+	var prices []ForwardPrice
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		if err = json.Unmarshal(data, &prices); err != nil {
+			return nil, fmt.Errorf("could not parse forward curve %s: %v", filename, err)
+		}
+	} else {
+		if prices, err = parseForwardCurveCSV(data); err != nil {
+			return nil, fmt.Errorf("could not parse forward curve %s: %v", filename, err)
+		}
+	}
+
+	curve, err := NewForwardCurve(prices)
+	if err != nil {
+		return nil, fmt.Errorf("invalid forward curve %s: %v", filename, err)
+	}
+	curve.Source = filename
+	return curve, nil
+}
+
+// NewForwardCurve validates forward prices and converts them to DKK/MWh
+func NewForwardCurve(prices []ForwardPrice) (*ForwardCurve, error) {
+	curve := &ForwardCurve{prices: make(map[string]map[string]float64)}
+
+	for i, price := range prices {
+		area := strings.ToUpper(strings.TrimSpace(price.Area))
+		if area != "DK1" && area != "DK2" {
+			return nil, fmt.Errorf("entry %d: invalid price area %q (expected DK1 or DK2)", i+1, price.Area)
+		}
+
+		period := strings.ToUpper(strings.TrimSpace(price.Period))
+		if err := checkForwardPeriod(period); err != nil {
+			return nil, fmt.Errorf("entry %d: %v", i+1, err)
+		}
+
+		priceDKK := price.Price
+		switch strings.ToUpper(strings.TrimSpace(price.Unit)) {
+		case "", "DKK/MWH":
+		case "EUR/MWH":
+			priceDKK = price.Price * energinet.EURToDKK
+		default:
+			return nil, fmt.Errorf("entry %d: invalid unit %q (expected DKK/MWh or EUR/MWh)", i+1, price.Unit)
+		}
+
+		if curve.prices[area] == nil {
+			curve.prices[area] = make(map[string]float64)
+		}
+		if _, exists := curve.prices[area][period]; exists {
+			return nil, fmt.Errorf("entry %d: %s %s is listed twice", i+1, area, period)
+		}
+		curve.prices[area][period] = priceDKK
+	}
+
+	if len(curve.prices) == 0 {
+		return nil, fmt.Errorf("no prices")
+	}
+	return curve, nil
+}
+
+// BasePrice returns the forward price in DKK/MWh for the hour starting at t: the price of its
+// month if the curve has one, otherwise the price of its quarter
+func (curve *ForwardCurve) BasePrice(area string, t time.Time) (float64, string, error) {
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+	local := t.In(copenhagen)
+
+	month := local.Format("2006-01")
+	if price, exists := curve.prices[area][month]; exists {
+		return price, month, nil
+	}
+	quarter := fmt.Sprintf("%d-Q%d", local.Year(), (int(local.Month())-1)/3+1)
+	if price, exists := curve.prices[area][quarter]; exists {
+		return price, quarter, nil
+	}
+
+	return 0, "", fmt.Errorf("forward curve has no %s price for %s or %s", area, month, quarter)
+}
+
+// EstimateSpotPrices prices every hour of [startDate, endDate) at the forward price of its month
+// or quarter, shaped by the intraday profile so every day averages the forward price
+func (curve *ForwardCurve) EstimateSpotPrices(startDate, endDate time.Time, priceArea string) ([]energinet.SpotPriceRecord, []string, error) {
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+
+	var spotPrices []energinet.SpotPriceRecord
+	usedPeriods := make(map[string]bool)
+
+	for currentTime := startDate; currentTime.Before(endDate); currentTime = currentTime.Add(1 * time.Hour) {
+		basePrice, period, err := curve.BasePrice(priceArea, currentTime)
+		if err != nil {
+			return nil, nil, err
+		}
+		usedPeriods[period] = true
+
+		// Average weight of the hours of the day, which has 23, 24 or 25 hours
+		local := currentTime.In(copenhagen)
+		day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, copenhagen)
+		var dayWeight float64
+		hours := 0
+		for hour := day; hour.Before(day.AddDate(0, 0, 1)); hour = hour.Add(time.Hour) {
+			dayWeight += intradayPriceWeight(hour.In(copenhagen))
+			hours++
+		}
+
+		price := basePrice * intradayPriceWeight(local) / (dayWeight / float64(hours))
+		spotPrices = append(spotPrices, energinet.SpotPriceRecord{
+			HourUTC:      currentTime.UTC().Format(energinet.RecordTimeLayout),
+			HourDK:       local.Format(energinet.RecordTimeLayout),
+			PriceArea:    priceArea,
+			SpotPriceDKK: price,
+			SpotPriceEUR: price / energinet.EURToDKK,
+		})
+	}
+
+	periods := make([]string, 0, len(usedPeriods))
+	for period := range usedPeriods {
+		periods = append(periods, period)
+	}
+	sort.Strings(periods)
+
+	return spotPrices, periods, nil
+}
+
+// intradayPriceWeight returns the relative spot price of an hour within its day
+func intradayPriceWeight(local time.Time) float64 {
+	weight := intradayPriceWeights[local.Hour()]
+	if isWeekend(local) {
+		return 1 + (weight-1)*weekendPriceSwing
+	}
+	return weight
+}
+
+// checkForwardPeriod checks that a period is a month like 2027-01 or a quarter like 2027-Q1
+func checkForwardPeriod(period string) error {
+	if _, err := time.Parse("2006-01", period); err == nil {
+		return nil
+	}
+
+	if len(period) == 7 && period[4:6] == "-Q" {
+		_, yearErr := strconv.Atoi(period[:4])
+		quarter, quarterErr := strconv.Atoi(period[6:])
+		if yearErr == nil && quarterErr == nil && quarter >= 1 && quarter <= 4 {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid period %q (expected e.g. 2027-01 or 2027-Q1)", period)
+}
+
+// parseForwardCurveCSV reads a comma or semicolon separated forward curve with a header row
+func parseForwardCurveCSV(data []byte) ([]ForwardPrice, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	if firstLine, _, _ := bytes.Cut(data, []byte("\n")); bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("expected a header row and at least one price")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"area", "period", "price"} {
+		if _, exists := columns[required]; !exists {
+			return nil, fmt.Errorf("missing column %q (found %s)", required, strings.Join(rows[0], ", "))
+		}
+	}
+
+	var prices []ForwardPrice
+	for lineNumber, row := range rows[1:] {
+		cell := func(name string) string {
+			i, exists := columns[name]
+			if !exists || i >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+		if cell("area") == "" && cell("period") == "" {
+			continue
+		}
+
+		price, err := strconv.ParseFloat(strings.ReplaceAll(cell("price"), ",", "."), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid price %q", lineNumber+2, cell("price"))
+		}
+		prices = append(prices, ForwardPrice{Area: cell("area"), Period: cell("period"), Price: price, Unit: cell("unit")})
+	}
+
+	return prices, nil
+}
+
+// ForwardCurveSpotPrices estimates spot prices from a forward price curve shaped by
+// a typical intraday profile. It is registered by the caller once a curve is loaded.
+type ForwardCurveSpotPrices struct {
+	Curve *ForwardCurve
+}
+
+func (ForwardCurveSpotPrices) Name() string { return "forward" }
+
+func (f ForwardCurveSpotPrices) EstimateSpotPrices(ctx context.Context, request EstimationRequest) ([]energinet.SpotPriceRecord, string, error) {
+	spotPrices, periods, err := f.Curve.EstimateSpotPrices(request.Start, request.End, request.PriceArea)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var used []string
+	for _, period := range periods {
+		used = append(used, fmt.Sprintf("%s %.2f DKK/MWh", period, f.Curve.prices[request.PriceArea][period]))
+	}
+	return spotPrices, fmt.Sprintf("Forward price curve %s shaped by intraday profile (%s %s)",
+		filepath.Base(f.Curve.Source), request.PriceArea, strings.Join(used, ", ")), nil
+}
@@ -3,6 +3,7 @@ package billing
 import (
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"electricity-invoice-calculator/lib/utils"
@@ -297,3 +298,51 @@ func DisplaySelectedPeriod(period Period) {
 	utils.PrintInfo(fmt.Sprintf("To: %s (exclusive)", period.End.Format("2006-01-02 15:04:05 MST")))
 	utils.PrintInfo(fmt.Sprintf("Time zone: %s", period.Start.Location()))
 }
+
+// Parses billing frequency from a command line value
+func ParseBillingFrequency(value string) (BillingFrequency, error) {
+	switch BillingFrequency(strings.ToLower(strings.TrimSpace(value))) {
+	case Monthly:
+		return Monthly, nil
+	case Quarterly:
+		return Quarterly, nil
+	default:
+		return "", fmt.Errorf("invalid billing frequency %q (expected %s or %s)", value, Monthly, Quarterly)
+	}
+}
+
+// Parses calculation type from a command line value
+func ParseCalculationType(value string) (CalculationType, error) {
+	switch CalculationType(strings.ToLower(strings.TrimSpace(value))) {
+	case Historical:
+		return Historical, nil
+	case Aconto:
+		return Aconto, nil
+	default:
+		return "", fmt.Errorf("invalid calculation type %q (expected %s or %s)", value, Historical, Aconto)
+	}
+}
+
+// Returns the short identifier of a period, e.g. 2025-07 (monthly) or 2025-Q3 (quarterly)
+func PeriodKey(period Period) string {
+	if period.Frequency == Quarterly {
+		quarter := ((int(period.Start.Month()) - 1) / 3) + 1
+		return fmt.Sprintf("%d-Q%d", period.Start.Year(), quarter)
+	}
+	return period.Start.Format("2006-01")
+}
+
+// Finds the period matching a short identifier such as 2025-07 or 2025-Q3
+func FindPeriod(periods []Period, key string) (Period, error) {
+	key = strings.ToUpper(strings.TrimSpace(key))
+
+	available := make([]string, len(periods))
+	for i, period := range periods {
+		available[i] = PeriodKey(period)
+		if available[i] == key {
+			return period, nil
+		}
+	}
+
+	return Period{}, fmt.Errorf("period %s is not available (available: %s)", key, strings.Join(available, ", "))
+}
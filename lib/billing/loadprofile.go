@@ -0,0 +1,105 @@
+package billing
+
+import (
+	"electricity-invoice-calculator/lib/eloverblik"
+	"fmt"
+	"time"
+)
+
+// Share of the annual consumption of a Danish household per month (January first).
+// Winter months carry more consumption because of lighting, heating and time spent indoors.
+var monthlyConsumptionShares = [12]float64{
+	0.102, 0.090, 0.089, 0.079, 0.074, 0.067,
+	0.066, 0.069, 0.073, 0.084, 0.095, 0.112,
+}
+
+// Relative consumption per Copenhagen hour of day (00-01 first) on working days:
+// low at night, a morning peak and the evening peak from 17 to 21
+var weekdayHourWeights = [24]float64{
+	0.55, 0.48, 0.45, 0.44, 0.45, 0.52, 0.78, 1.02,
+	0.95, 0.85, 0.82, 0.84, 0.86, 0.84, 0.85, 0.95,
+	1.18, 1.58, 1.72, 1.62, 1.42, 1.22, 0.98, 0.72,
+}
+
+// Relative consumption per hour of day on weekends and holidays: a later and flatter morning
+var weekendHourWeights = [24]float64{
+	0.60, 0.52, 0.47, 0.45, 0.45, 0.47, 0.55, 0.72,
+	0.92, 1.05, 1.10, 1.12, 1.12, 1.05, 1.00, 1.05,
+	1.22, 1.55, 1.68, 1.58, 1.40, 1.22, 1.00, 0.76,
+}
+
+// Relative consumption of a weekend day compared to a working day
+const weekendDayWeight = 1.08
+
+// EstimateProfileConsumptionForPeriod creates estimated hourly consumption data from
+// estimatedAnnualVolume using a Danish residential load profile: every month gets its
+// seasonal share of the annual volume, spread over the days of the month (weekends a little
+// higher) and over the hours of each day by the hour-of-day shape of working days or weekends.
+func EstimateProfileConsumptionForPeriod(estimatedAnnualVolume int, startDate, endDate time.Time) ([]eloverblik.HourlyConsumption, error) {
+	if !startDate.Before(endDate) {
+		return nil, fmt.Errorf("invalid period: %s is not before %s", startDate, endDate)
+	}
+
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+
+	// Total day weight per month, computed when a month is first seen
+	monthWeights := make(map[string]float64)
+	monthWeight := func(local time.Time) float64 {
+		key := local.Format("2006-01")
+		if weight, exists := monthWeights[key]; exists {
+			return weight
+		}
+		var weight float64
+		for day := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, copenhagen); day.Month() == local.Month(); day = day.AddDate(0, 0, 1) {
+			weight += dayWeight(day)
+		}
+		monthWeights[key] = weight
+		return weight
+	}
+
+	var estimatedConsumption []eloverblik.HourlyConsumption
+
+	currentTime := startDate
+	for currentTime.Before(endDate) {
+		local := currentTime.In(copenhagen)
+		day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, copenhagen)
+
+		// Consumption of the day, spread over its 23, 24 or 25 hours by their weights
+		dayConsumption := float64(estimatedAnnualVolume) * monthlyConsumptionShares[local.Month()-1] * dayWeight(day) / monthWeight(local)
+		var dayTotalWeight float64
+		for hour := day; hour.Before(day.AddDate(0, 0, 1)); hour = hour.Add(time.Hour) {
+			dayTotalWeight += hourWeight(hour.In(copenhagen))
+		}
+
+		estimatedConsumption = append(estimatedConsumption, eloverblik.HourlyConsumption{
+			DateTime:    currentTime,
+			Resolution:  time.Hour,
+			Consumption: dayConsumption * hourWeight(local) / dayTotalWeight,
+			Quality:     "ESTIMATED",
+		})
+		currentTime = currentTime.Add(1 * time.Hour)
+	}
+
+	return estimatedConsumption, nil
+}
+
+// isWeekend reports whether a Copenhagen time falls on a Saturday or Sunday
+func isWeekend(local time.Time) bool {
+	return local.Weekday() == time.Saturday || local.Weekday() == time.Sunday
+}
+
+// dayWeight returns the relative consumption of a day
+func dayWeight(day time.Time) float64 {
+	if isWeekend(day) {
+		return weekendDayWeight
+	}
+	return 1.0
+}
+
+// hourWeight returns the relative consumption of an hour within its day
+func hourWeight(local time.Time) float64 {
+	if isWeekend(local) {
+		return weekendHourWeights[local.Hour()]
+	}
+	return weekdayHourWeights[local.Hour()]
+}
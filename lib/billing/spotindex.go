@@ -0,0 +1,58 @@
+package billing
+
+import (
+	"electricity-invoice-calculator/lib/energinet"
+	"fmt"
+	"time"
+)
+
+// SpotPriceIndex holds spot prices in DKK/kWh by the UTC instant their interval starts,
+// so every hour or quarter-hour is looked up in constant time
+type SpotPriceIndex struct {
+	prices map[int64]float64 // Unix time of the interval start -> DKK/kWh
+}
+
+// NewSpotPriceIndex indexes spot price records by HourUTC. Records with an unparseable
+// HourUTC are left out; of duplicated records the first one is used.
+func NewSpotPriceIndex(spotPrices []energinet.SpotPriceRecord) *SpotPriceIndex {
+	index := &SpotPriceIndex{prices: make(map[int64]float64, len(spotPrices))}
+
+	for _, record := range spotPrices {
+		start, err := time.ParseInLocation(energinet.RecordTimeLayout, record.HourUTC, time.UTC)
+		if err != nil {
+			continue
+		}
+		if _, exists := index.prices[start.Unix()]; exists {
+			continue
+		}
+		// Convert from DKK/MWh to DKK/kWh
+		index.prices[start.Unix()] = energinet.ConvertToKWh(record.SpotPriceDKK)
+	}
+
+	return index
+}
+
+// Lookup returns the spot price in DKK/kWh for the interval starting at t.
+// A quarter-hour is priced at its own 15-minute price if present, otherwise at the price of its hour.
+func (index *SpotPriceIndex) Lookup(t time.Time) (float64, bool) {
+	if price, exists := index.prices[t.Unix()]; exists {
+		return price, true
+	}
+	price, exists := index.prices[t.Truncate(time.Hour).Unix()]
+	return price, exists
+}
+
+// Price is Lookup with an error naming the interval if no price exists
+func (index *SpotPriceIndex) Price(t time.Time) (float64, error) {
+	price, exists := index.Lookup(t)
+	if !exists {
+		copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+		return 0, fmt.Errorf("no spot price found for hour %s", t.In(copenhagen).Format("2006-01-02T15:04:05-07:00"))
+	}
+	return price, nil
+}
+
+// Len returns the number of indexed intervals
+func (index *SpotPriceIndex) Len() int {
+	return len(index.prices)
+}
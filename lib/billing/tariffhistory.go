@@ -0,0 +1,203 @@
+package billing
+
+import (
+	"context"
+	"electricity-invoice-calculator/lib/eloverblik"
+	"electricity-invoice-calculator/lib/energinet"
+	"electricity-invoice-calculator/lib/utils"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Charge codes of Energinet's national tariffs by the name Eloverblik uses for them
+var nationalTariffCodes = map[string]string{
+	"Systemtarif":            energinet.SystemTariffCode,
+	"Transmissions nettarif": energinet.TransmissionTariffCode,
+	"Elafgift":               energinet.ElectricityTaxCode,
+}
+
+// Upper bound of the DatahubPricelist queries of grid tariffs. They include the versions valid
+// today, which identify the charge code, and keep the same query (and cache snapshot) every run.
+var gridTariffHorizon = time.Date(2100, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// HistoricalCharges returns a copy of chargesData where each tariff is supplemented with
+// the versions from DatahubPricelist that were valid during [startDate, endDate).
+// Eloverblik only returns the currently valid charges, so without this a past period
+// would be priced at today's tariffs. Tariffs are looked up by owner GLN and charge code.
+// Eloverblik does not return the charge code, so the code of a grid tariff is found from its
+// owner's tariffs valid today: the one with the same prices, or else the same name.
+// Tariffs without history in DatahubPricelist are kept as returned by Eloverblik.
+func HistoricalCharges(ctx context.Context, client *energinet.Client, chargesData *eloverblik.ChargesResult, startDate, endDate time.Time) (*eloverblik.ChargesResult, error) {
+	result := *chargesData
+	result.Tariffs = nil
+
+	for _, tariff := range chargesData.Tariffs {
+		var records []energinet.PricelistRecord
+		var err error
+		if code, ok := nationalTariffCodes[tariff.Name]; ok && tariff.Owner == energinet.EnerginetGLN {
+			records, err = client.GetDatahubPricelist(ctx, startDate, endDate, energinet.PricelistFilter{
+				GLNNumber:       tariff.Owner,
+				ChargeTypeCodes: []string{code},
+				ChargeType:      energinet.TariffChargeType,
+			})
+		} else {
+			records, err = gridTariffHistory(ctx, client, tariff, startDate, endDate)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tariff history for %s: %w", tariff.Name, err)
+		}
+
+		if len(records) == 0 {
+			utils.PrintWarning(fmt.Sprintf("No tariff history found for %s, using current tariff", tariff.Name))
+		}
+
+		for _, record := range records {
+			result.Tariffs = append(result.Tariffs, tariffFromPricelist(record, tariff.Name))
+		}
+
+		// The current version stays as well; SelectValidTariffs picks the right one per hour
+		result.Tariffs = append(result.Tariffs, tariff)
+	}
+
+	return &result, nil
+}
+
+// gridTariffHistory returns the versions of a grid tariff valid during [startDate, endDate).
+// The charge code is identified among the owner's tariffs; only if no code can be
+// identified are the versions matched by name.
+func gridTariffHistory(ctx context.Context, client *energinet.Client, tariff eloverblik.Tariff, startDate, endDate time.Time) ([]energinet.PricelistRecord, error) {
+	ownerRecords, err := client.GetDatahubPricelist(ctx, startDate, gridTariffHorizon, energinet.PricelistFilter{
+		GLNNumber:  tariff.Owner,
+		ChargeType: energinet.TariffChargeType,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	code := gridTariffCode(tariff, ownerRecords)
+	if code == "" {
+		utils.PrintWarning(fmt.Sprintf("Could not identify the charge code of %s, matching its history by name", tariff.Name))
+	}
+
+	var records []energinet.PricelistRecord
+	for _, record := range ownerRecords {
+		matches := record.ChargeTypeCode == code
+		if code == "" {
+			matches = record.Note == tariff.Name
+		}
+		if !matches {
+			continue
+		}
+		if validFrom, err := time.ParseInLocation(energinet.PricelistDateLayout, record.ValidFrom, tariffLocation); err == nil && !validFrom.Before(endDate) {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// gridTariffCode returns the charge code of the owner's tariff that matches tariff at the time
+// it is valid: the only code with the same prices, or among several the only one with the same
+// name. It returns an empty string if no single code matches.
+func gridTariffCode(tariff eloverblik.Tariff, ownerRecords []energinet.PricelistRecord) string {
+	// Eloverblik returns the tariff valid today, or a version that has ended or not started yet
+	at := time.Now()
+	if validFrom := tariff.ValidFrom(); at.Before(validFrom) {
+		at = validFrom
+	} else if tariff.ValidToDate != nil {
+		if validTo, err := eloverblik.ParseChargeDate(*tariff.ValidToDate); err == nil && !at.Before(validTo) {
+			at = validTo.Add(-time.Hour)
+		}
+	}
+
+	samePrices := make(map[string]bool)
+	sameName := make(map[string]bool)
+	for _, record := range ownerRecords {
+		if !record.IsValidAt(at) {
+			continue
+		}
+		if pricesMatch(tariff, record) {
+			samePrices[record.ChargeTypeCode] = true
+		}
+		if record.Note == tariff.Name {
+			sameName[record.ChargeTypeCode] = true
+		}
+	}
+
+	if len(samePrices) == 1 {
+		return singleKey(samePrices)
+	}
+
+	// Several codes with the same prices, or none (e.g. a price change Eloverblik does not show yet)
+	matches := make(map[string]bool)
+	for code := range sameName {
+		if len(samePrices) == 0 || samePrices[code] {
+			matches[code] = true
+		}
+	}
+	if len(matches) == 1 {
+		return singleKey(matches)
+	}
+
+	return ""
+}
+
+// pricesMatch reports whether a DatahubPricelist record has the prices of an Eloverblik tariff
+func pricesMatch(tariff eloverblik.Tariff, record energinet.PricelistRecord) bool {
+	if len(tariff.Prices) == 0 {
+		return false
+	}
+
+	// A flat tariff has one price for all positions
+	pricesByPosition := make(map[string]float64)
+	for _, price := range tariff.Prices {
+		pricesByPosition[price.Position] = price.Price
+	}
+
+	for i, recordPrice := range record.HourlyPrices() {
+		price, exists := pricesByPosition[strconv.Itoa(i+1)]
+		if len(tariff.Prices) == 1 {
+			price, exists = tariff.Prices[0].Price, true
+		}
+		if !exists || math.Abs(price-recordPrice) > 1e-6 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// singleKey returns the key of a map with one entry
+func singleKey(set map[string]bool) string {
+	for key := range set {
+		return key
+	}
+	return ""
+}
+
+// tariffFromPricelist converts a DatahubPricelist record to the Eloverblik tariff structure
+func tariffFromPricelist(record energinet.PricelistRecord, name string) eloverblik.Tariff {
+	tariff := eloverblik.Tariff{
+		Name:          name,
+		Description:   record.Description,
+		Owner:         record.GLNNumber,
+		ValidFromDate: record.ValidFrom,
+		ValidToDate:   record.ValidTo,
+	}
+
+	if record.IsFlat() {
+		tariff.PeriodType = "P1D"
+		tariff.Prices = []eloverblik.Price{{Position: "1", Price: record.HourlyPrices()[0]}}
+		return tariff
+	}
+
+	tariff.PeriodType = "PT1H"
+	for i, price := range record.HourlyPrices() {
+		tariff.Prices = append(tariff.Prices, eloverblik.Price{Position: strconv.Itoa(i + 1), Price: price})
+	}
+
+	return tariff
+}
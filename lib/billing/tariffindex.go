@@ -0,0 +1,83 @@
+package billing
+
+import (
+	"electricity-invoice-calculator/lib/eloverblik"
+	"sort"
+	"time"
+)
+
+// TariffIndex holds tariffs with their validity dates parsed once, so the tariffs valid in
+// every hour of a long period are selected without parsing the charge dates again.
+// The valid tariffs only change at validity dates; they are selected once per interval
+// between two such dates. A TariffIndex is not safe for concurrent use.
+type TariffIndex struct {
+	tariffs    []eloverblik.Tariff
+	validFrom  []time.Time // zero if unknown
+	validTo    []time.Time // zero if open-ended or unknown
+	boundaries []time.Time // sorted validity dates of all tariffs
+	selected   map[int][]eloverblik.Tariff
+}
+
+// NewTariffIndex parses the validity dates of tariffs. Dates that cannot be parsed do not
+// restrict validity, as in eloverblik.Tariff.IsValidAt.
+func NewTariffIndex(tariffs []eloverblik.Tariff) *TariffIndex {
+	index := &TariffIndex{
+		tariffs:   tariffs,
+		validFrom: make([]time.Time, len(tariffs)),
+		validTo:   make([]time.Time, len(tariffs)),
+		selected:  make(map[int][]eloverblik.Tariff),
+	}
+
+	for i, tariff := range tariffs {
+		if validFrom, err := eloverblik.ParseChargeDate(tariff.ValidFromDate); err == nil {
+			index.validFrom[i] = validFrom
+			index.boundaries = append(index.boundaries, validFrom)
+		}
+		if tariff.ValidToDate != nil && *tariff.ValidToDate != "" {
+			if validTo, err := eloverblik.ParseChargeDate(*tariff.ValidToDate); err == nil {
+				index.validTo[i] = validTo
+				index.boundaries = append(index.boundaries, validTo)
+			}
+		}
+	}
+	sort.Slice(index.boundaries, func(i, j int) bool { return index.boundaries[i].Before(index.boundaries[j]) })
+
+	return index
+}
+
+// ValidAt returns the tariffs valid at the given time; see SelectValidTariffs
+func (index *TariffIndex) ValidAt(at time.Time) []eloverblik.Tariff {
+	// Number of validity dates at or before at, which is the same for every time until the next one
+	segment := sort.Search(len(index.boundaries), func(i int) bool { return index.boundaries[i].After(at) })
+	if valid, exists := index.selected[segment]; exists {
+		return valid
+	}
+
+	var valid []eloverblik.Tariff
+	var validFrom []time.Time
+	indexByName := make(map[string]int)
+
+	for i, tariff := range index.tariffs {
+		if !index.validFrom[i].IsZero() && at.Before(index.validFrom[i]) {
+			continue
+		}
+		if !index.validTo[i].IsZero() && !at.Before(index.validTo[i]) {
+			continue
+		}
+
+		if j, exists := indexByName[tariff.Name]; exists {
+			if index.validFrom[i].After(validFrom[j]) {
+				valid[j] = tariff
+				validFrom[j] = index.validFrom[i]
+			}
+			continue
+		}
+
+		indexByName[tariff.Name] = len(valid)
+		valid = append(valid, tariff)
+		validFrom = append(validFrom, index.validFrom[i])
+	}
+
+	index.selected[segment] = valid
+	return valid
+}
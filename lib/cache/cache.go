@@ -0,0 +1,367 @@
+// Package cache stores metered consumption, spot prices and other API responses on disk between runs.
+//
+// Time series records are kept per series (e.g. one meter point or price area) in one JSON file
+// per month, grouped by Copenhagen day. A period that was calculated before is read locally and
+// only days that are missing or were incomplete are requested from the APIs again. Series whose
+// data may still be corrected, like metered consumption, also request days again that were
+// fetched within their revision window, so late corrections replace the first readings.
+//
+// Other responses (meter points, charges, ...) are stored as snapshots, the latest one per request.
+// An offline store never calls the APIs and serves everything from the time series and snapshots.
+package cache
+
+import (
+	"context"
+	"electricity-invoice-calculator/lib/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Layout of the day keys in the month files
+const dayLayout = "2006-01-02"
+
+// Store is a cache directory
+type Store struct {
+	Dir     string
+	Offline bool // serve only stored data and never call fetch
+}
+
+// Returns the default cache directory in the user cache directory
+func DefaultDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("could not find user cache directory: %v", err)
+	}
+
+	return filepath.Join(cacheDir, "electricity-invoice-calculator"), nil
+}
+
+// Creates a store in dir. The directory is created when the first day is stored.
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// Series describes how the records of one time series are cached
+type Series[T any] struct {
+	Name   string                       // path of the series in the store, e.g. "spotprices/DK1/60min"
+	TimeOf func(record T) time.Time     // start of the interval a record covers
+	Length func(record T) time.Duration // length of the interval a record covers
+
+	// How long after a day the API may still revise its records, 0 if they are final.
+	// Complete days fetched within this window are fetched again when online.
+	RevisionWindow time.Duration
+}
+
+// cachedDay holds the records of one day. Incomplete days are fetched again when online.
+type cachedDay[T any] struct {
+	Complete  bool      `json:"complete"`
+	FetchedAt time.Time `json:"fetchedAt"`
+	Records   []T       `json:"records"`
+}
+
+// isFinal reports whether the records of a complete day can no longer be revised by the API
+func (d cachedDay[T]) isFinal(day time.Time, revisionWindow time.Duration) bool {
+	if revisionWindow == 0 {
+		return d.Complete
+	}
+	return d.Complete && !d.FetchedAt.Before(day.AddDate(0, 0, 1).Add(revisionWindow))
+}
+
+// MissingError reports the hours of a series an offline store has no data for
+type MissingError struct {
+	Series string
+	Hours  []time.Time // start of every missing hour
+}
+
+func (e *MissingError) Error() string {
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+
+	// Show runs of consecutive hours as ranges
+	var ranges []string
+	for i := 0; i < len(e.Hours); {
+		j := i + 1
+		for j < len(e.Hours) && e.Hours[j].Equal(e.Hours[j-1].Add(time.Hour)) {
+			j++
+		}
+		ranges = append(ranges, fmt.Sprintf("%s to %s (%d hours)",
+			e.Hours[i].In(copenhagen).Format("2006-01-02 15:04"),
+			e.Hours[j-1].Add(time.Hour).In(copenhagen).Format("2006-01-02 15:04"),
+			j-i))
+		i = j
+	}
+
+	return fmt.Sprintf("%d hours of %s are missing from the cache: %s", len(e.Hours), e.Series, strings.Join(ranges, ", "))
+}
+
+// FetchFunc gets the records of [start, end) from an API. start and end are Copenhagen midnights.
+type FetchFunc[T any] func(ctx context.Context, start, end time.Time) ([]T, error)
+
+// Fetch returns the records of series in [start, end). Days found complete in the store are read
+// from it, unless they were fetched within the revision window of the series; every run of other
+// days is requested with fetch, and the fetched days are stored.
+// A nil store requests the whole period.
+//
+// An offline store never calls fetch. If hours are missing it returns the stored records
+// together with a *MissingError listing them.
+func Fetch[T any](ctx context.Context, store *Store, series Series[T], start, end time.Time, fetch FetchFunc[T]) ([]T, error) {
+	days := daysOf(start, end)
+	if len(days) == 0 {
+		return nil, nil
+	}
+
+	if store == nil {
+		records, err := fetch(ctx, days[0], days[len(days)-1].AddDate(0, 0, 1))
+		if err != nil {
+			return nil, err
+		}
+		return inRange(records, series.TimeOf, start, end), nil
+	}
+
+	months := make(map[string]map[string]cachedDay[T]) // month file -> day -> records
+	dirtyMonths := make(map[string]bool)
+	recordsByDay := make(map[string][]T)
+
+	// Look up every day in the store
+	var missing []time.Time
+	for _, day := range days {
+		key := day.Format(dayLayout)
+		cached, exists := loadMonth(store, series.Name, day, months)[key]
+		if exists && (cached.isFinal(day, series.RevisionWindow) || store.Offline) {
+			recordsByDay[key] = cached.Records
+			continue
+		}
+		missing = append(missing, day)
+	}
+
+	// Fetch the missing days, one request per run of consecutive days
+	if !store.Offline {
+		fetchedAt := time.Now()
+		for _, run := range consecutiveRuns(missing) {
+			runEnd := run[len(run)-1].AddDate(0, 0, 1)
+			records, err := fetch(ctx, run[0], runEnd)
+			if err != nil {
+				return nil, err
+			}
+
+			fetchedByDay := groupByDay(records, series.TimeOf)
+			for _, day := range run {
+				key := day.Format(dayLayout)
+				dayRecords := fetchedByDay[key]
+				recordsByDay[key] = dayRecords
+
+				if len(dayRecords) > 0 {
+					nextDay := day.AddDate(0, 0, 1)
+					loadMonth(store, series.Name, day, months)[key] = cachedDay[T]{
+						Complete:  covered(dayRecords, series.Length) == nextDay.Sub(day),
+						FetchedAt: fetchedAt,
+						Records:   dayRecords,
+					}
+					dirtyMonths[monthKey(day)] = true
+				}
+			}
+		}
+	}
+
+	for month := range dirtyMonths {
+		if err := store.saveMonth(series.Name, month, months[month]); err != nil {
+			utils.PrintWarning(fmt.Sprintf("Could not write cache: %v", err))
+		}
+	}
+
+	var result []T
+	for _, day := range days {
+		result = append(result, recordsByDay[day.Format(dayLayout)]...)
+	}
+	result = inRange(result, series.TimeOf, start, end)
+
+	if store.Offline {
+		if hours := missingHours(result, series, start, end); len(hours) > 0 {
+			return result, &MissingError{Series: series.Name, Hours: hours}
+		}
+	}
+
+	return result, nil
+}
+
+// Snapshot returns the response stored under name if the store is offline. Otherwise it calls
+// fetch and stores the response for later offline runs. A nil store only calls fetch.
+func Snapshot[T any](ctx context.Context, store *Store, name string, fetch func(ctx context.Context) (T, error)) (T, error) {
+	var response T
+	if store == nil {
+		return fetch(ctx)
+	}
+
+	path := filepath.Join(store.Dir, "snapshots", filepath.FromSlash(name)+".json")
+
+	if store.Offline {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return response, fmt.Errorf("%s is not in the cache; run once without --offline first", name)
+		}
+		if err != nil {
+			return response, fmt.Errorf("could not read %s: %v", path, err)
+		}
+		if err = json.Unmarshal(data, &response); err != nil {
+			return response, fmt.Errorf("could not parse %s: %v", path, err)
+		}
+		return response, nil
+	}
+
+	response, err := fetch(ctx)
+	if err != nil {
+		return response, err
+	}
+
+	if err = writeJSONFile(path, response); err != nil {
+		utils.PrintWarning(fmt.Sprintf("Could not write cache: %v", err))
+	}
+
+	return response, nil
+}
+
+// loadMonth returns the cached days of the month containing day, reading the month file on first use
+func loadMonth[T any](s *Store, series string, day time.Time, months map[string]map[string]cachedDay[T]) map[string]cachedDay[T] {
+	key := monthKey(day)
+	if month, loaded := months[key]; loaded {
+		return month
+	}
+
+	month := make(map[string]cachedDay[T])
+	data, err := os.ReadFile(s.monthPath(series, key))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		utils.PrintWarning(fmt.Sprintf("Could not read cache: %v", err))
+	}
+	if err == nil {
+		if err = json.Unmarshal(data, &month); err != nil {
+			// A corrupt month file is not fatal, the month is simply fetched again
+			utils.PrintWarning(fmt.Sprintf("Ignoring corrupt cache file %s: %v", s.monthPath(series, key), err))
+			month = make(map[string]cachedDay[T])
+		}
+	}
+
+	months[key] = month
+	return month
+}
+
+// saveMonth writes a month file
+func (s *Store) saveMonth(series, month string, days any) error {
+	return writeJSONFile(s.monthPath(series, month), days)
+}
+
+// writeJSONFile encodes v to path, replacing the file atomically
+func writeJSONFile(path string, v any) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("could not create %s: %v", filepath.Dir(path), err)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("could not encode %s: %v", path, err)
+	}
+
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("could not write %s: %v", tmp, err)
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("could not replace %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// monthPath returns the file holding one month of a series
+func (s *Store) monthPath(series, month string) string {
+	return filepath.Join(s.Dir, filepath.FromSlash(series), month+".json")
+}
+
+// monthKey returns the name of the month file containing day
+func monthKey(day time.Time) string {
+	return day.Format("2006-01")
+}
+
+// daysOf returns the Copenhagen midnights of all days overlapping [start, end)
+func daysOf(start, end time.Time) []time.Time {
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+	local := start.In(copenhagen)
+
+	var days []time.Time
+	for day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, copenhagen); day.Before(end); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	return days
+}
+
+// consecutiveRuns splits sorted days into runs of consecutive days
+func consecutiveRuns(days []time.Time) [][]time.Time {
+	var runs [][]time.Time
+	for i, day := range days {
+		if i > 0 && day.Equal(days[i-1].AddDate(0, 0, 1)) {
+			runs[len(runs)-1] = append(runs[len(runs)-1], day)
+			continue
+		}
+		runs = append(runs, []time.Time{day})
+	}
+	return runs
+}
+
+// groupByDay groups records by the Copenhagen day they start in, sorted by time within the day
+func groupByDay[T any](records []T, timeOf func(T) time.Time) map[string][]T {
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+
+	byDay := make(map[string][]T)
+	for _, record := range records {
+		key := timeOf(record).In(copenhagen).Format(dayLayout)
+		byDay[key] = append(byDay[key], record)
+	}
+
+	for _, dayRecords := range byDay {
+		sort.SliceStable(dayRecords, func(i, j int) bool {
+			return timeOf(dayRecords[i]).Before(timeOf(dayRecords[j]))
+		})
+	}
+	return byDay
+}
+
+// inRange keeps the records starting in [start, end)
+func inRange[T any](records []T, timeOf func(T) time.Time, start, end time.Time) []T {
+	var kept []T
+	for _, record := range records {
+		t := timeOf(record)
+		if !t.Before(start) && t.Before(end) {
+			kept = append(kept, record)
+		}
+	}
+	return kept
+}
+
+// covered returns the total length of the intervals of records
+func covered[T any](records []T, length func(T) time.Duration) time.Duration {
+	var total time.Duration
+	for _, record := range records {
+		total += length(record)
+	}
+	return total
+}
+
+// missingHours returns the hours of [start, end) not fully covered by records
+func missingHours[T any](records []T, series Series[T], start, end time.Time) []time.Time {
+	coveredByHour := make(map[int64]time.Duration) // Unix time of the hour -> covered length
+	for _, record := range records {
+		coveredByHour[series.TimeOf(record).Truncate(time.Hour).Unix()] += series.Length(record)
+	}
+
+	var missing []time.Time
+	for hour := start.Truncate(time.Hour); hour.Before(end); hour = hour.Add(time.Hour) {
+		if coveredByHour[hour.Unix()] < time.Hour {
+			missing = append(missing, hour)
+		}
+	}
+	return missing
+}
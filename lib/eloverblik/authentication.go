@@ -1,6 +1,7 @@
 package eloverblik
 
 import (
+	"context"
 	"electricity-invoice-calculator/lib/utils"
 	"encoding/json"
 	"fmt"
@@ -43,10 +44,15 @@ func LoadAuthToken(filename string) (string, error) {
 }
 
 // Returns refresh token from JWT token
-func GetRefreshToken(jwtToken string) (string, error) {
-	url := "https://api.eloverblik.dk/customerapi/api/token"
+func GetRefreshToken(ctx context.Context, jwtToken string) (string, error) {
+	return requestRefreshToken(ctx, utils.DefaultHTTPClient, APIEndpoint, jwtToken)
+}
+
+// requestRefreshToken requests a data access token from the API at baseURL
+func requestRefreshToken(ctx context.Context, httpClient *utils.HTTPClient, baseURL, jwtToken string) (string, error) {
+	url := baseURL + "token"
 
-	response, err := utils.MakeRequestWithToken("GET", url, jwtToken, nil)
+	response, err := httpClient.MakeRequestWithToken(ctx, "GET", url, jwtToken, nil)
 	if err != nil {
 		return "", fmt.Errorf("token request failed: %v", err)
 	}
@@ -1,7 +1,8 @@
 package eloverblik
 
 import (
-	"electricity-invoice-calculator/lib/utils"
+	"context"
+	"electricity-invoice-calculator/lib/cache"
 	"encoding/json"
 	"fmt"
 	"time"
@@ -36,6 +37,54 @@ type Tariff struct {
 	PeriodType    string  `json:"periodType"`
 }
 
+// Layouts used by Eloverblik for charge validity dates
+var chargeDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// Time zone of charge dates, loaded once as validity is checked for every hour
+var chargeLocation, _ = time.LoadLocation("Europe/Copenhagen")
+
+// ParseChargeDate parses a validFromDate/validToDate value.
+// Dates without a time zone are interpreted in Copenhagen time.
+func ParseChargeDate(value string) (time.Time, error) {
+	for _, layout := range chargeDateLayouts {
+		if date, err := time.ParseInLocation(layout, value, chargeLocation); err == nil {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("could not parse charge date %q", value)
+}
+
+// ValidFrom returns the start of the validity interval, or the zero time if unknown
+func (t Tariff) ValidFrom() time.Time {
+	validFrom, err := ParseChargeDate(t.ValidFromDate)
+	if err != nil {
+		return time.Time{}
+	}
+	return validFrom
+}
+
+// IsValidAt reports whether the tariff applies at the given time.
+// The interval is [ValidFromDate, ValidToDate); a missing ValidToDate means open-ended.
+// Dates that cannot be parsed do not restrict validity.
+func (t Tariff) IsValidAt(at time.Time) bool {
+	if validFrom, err := ParseChargeDate(t.ValidFromDate); err == nil && at.Before(validFrom) {
+		return false
+	}
+
+	if t.ValidToDate != nil && *t.ValidToDate != "" {
+		if validTo, err := ParseChargeDate(*t.ValidToDate); err == nil && !at.Before(validTo) {
+			return false
+		}
+	}
+
+	return true
+}
+
 // ChargesResult contains the charges information for a meter point
 type ChargesResult struct {
 	Fees            []interface{}  `json:"fees"`
@@ -77,9 +126,17 @@ type BillingCalculation struct {
 	HourlyCharges         []HourlyCharge
 }
 
-// GetCharges fetches tariff and subscription information for a meter point
-func GetCharges(refreshToken, meterPointId string) (*ChargesResult, error) {
-	url := APIEndpoint + "meteringpoints/meteringpoint/getcharges"
+// GetCharges fetches tariff and subscription information for a meter point.
+// The response is snapshotted in c.Cache for offline runs.
+func (c *Client) GetCharges(ctx context.Context, meterPointId string) (*ChargesResult, error) {
+	return cache.Snapshot(ctx, c.Cache, "charges/"+meterPointId, func(ctx context.Context) (*ChargesResult, error) {
+		return c.fetchCharges(ctx, meterPointId)
+	})
+}
+
+// fetchCharges requests the charges of a meter point from the API
+func (c *Client) fetchCharges(ctx context.Context, meterPointId string) (*ChargesResult, error) {
+	url := c.BaseURL + "meteringpoints/meteringpoint/getcharges"
 
 	body := []byte(fmt.Sprintf(`{
 		"meteringPoints": {
@@ -87,13 +144,9 @@ func GetCharges(refreshToken, meterPointId string) (*ChargesResult, error) {
 		}
 	}`, meterPointId))
 
-	response, err := utils.MakeRequestWithToken("POST", url, refreshToken, body)
+	response, err := c.request(ctx, "POST", url, body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get charges: %v", err)
-	}
-
-	if err = utils.ValidateStatusOK(response); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get charges: %w", err)
 	}
 
 	var apiResponse ChargesAPIResponse
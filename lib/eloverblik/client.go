@@ -0,0 +1,128 @@
+package eloverblik
+
+import (
+	"context"
+	"electricity-invoice-calculator/lib/cache"
+	"electricity-invoice-calculator/lib/utils"
+	"fmt"
+	"sync"
+)
+
+// Client calls the Eloverblik customer API on behalf of the owner of a JWT token.
+// It obtains the short-lived data access token itself, reuses it between runs
+// through the token cache and refreshes it when the API rejects it with 401.
+type Client struct {
+	BaseURL string            // base URL of the customer API, ending in a slash
+	HTTP    *utils.HTTPClient // HTTP client used for all requests
+	Cache   *cache.Store      // local store of consumption data, nil to always request the API
+
+	jwtToken  string
+	cachePath string // empty disables the token cache
+
+	mu          sync.Mutex
+	accessToken string
+}
+
+// Creates a client for the JWT token from LoadAuthToken.
+// cachePath is the token cache file, e.g. from DefaultTokenCachePath, or empty to disable caching.
+// BaseURL and HTTP default to APIEndpoint and utils.DefaultHTTPClient and may be replaced before use,
+// as may Cache, which is nil (disabled) by default.
+func NewClient(jwtToken, cachePath string) *Client {
+	return &Client{
+		BaseURL:   APIEndpoint,
+		HTTP:      utils.DefaultHTTPClient,
+		jwtToken:  jwtToken,
+		cachePath: cachePath,
+	}
+}
+
+// Returns a valid data access token, from memory, the token cache or a new one from the API
+func (c *Client) AccessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.accessToken != "" {
+		return c.accessToken, nil
+	}
+
+	if c.cachePath != "" {
+		token, err := LoadCachedToken(c.cachePath, c.BaseURL, c.jwtToken)
+		if err != nil {
+			utils.PrintWarning(fmt.Sprintf("Could not read token cache: %v", err))
+		}
+		if token != "" {
+			c.accessToken = token
+			return token, nil
+		}
+	}
+
+	return c.mintAccessToken(ctx)
+}
+
+// Discards rejectedToken and mints a new data access token.
+// If another request already replaced rejectedToken, that token is returned instead.
+func (c *Client) refreshAccessToken(ctx context.Context, rejectedToken string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.accessToken != "" && c.accessToken != rejectedToken {
+		return c.accessToken, nil
+	}
+
+	if c.cachePath != "" {
+		if err := InvalidateCachedToken(c.cachePath); err != nil {
+			utils.PrintWarning(fmt.Sprintf("Could not clear token cache: %v", err))
+		}
+	}
+
+	return c.mintAccessToken(ctx)
+}
+
+// mintAccessToken gets a new data access token and caches it. Callers must hold c.mu.
+func (c *Client) mintAccessToken(ctx context.Context) (string, error) {
+	token, err := requestRefreshToken(ctx, c.HTTP, c.BaseURL, c.jwtToken)
+	if err != nil {
+		return "", err
+	}
+	c.accessToken = token
+
+	if c.cachePath != "" {
+		if err := SaveCachedToken(c.cachePath, c.BaseURL, c.jwtToken, token); err != nil {
+			utils.PrintWarning(fmt.Sprintf("Could not cache refresh token: %v", err))
+		}
+	}
+
+	return token, nil
+}
+
+// request sends an authenticated request and validates the response status.
+// A 401 response is retried once with a newly minted data access token.
+func (c *Client) request(ctx context.Context, method, url string, body []byte) (*utils.HTTPResponse, error) {
+	token, err := c.AccessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.HTTP.MakeRequestWithToken(ctx, method, url, token, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = utils.ValidateStatusOK(response); utils.IsUnauthorized(err) {
+		token, err = c.refreshAccessToken(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err = c.HTTP.MakeRequestWithToken(ctx, method, url, token, body)
+		if err != nil {
+			return nil, err
+		}
+		err = utils.ValidateStatusOK(response)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
@@ -1,6 +1,8 @@
 package eloverblik
 
 import (
+	"context"
+	"electricity-invoice-calculator/lib/cache"
 	"electricity-invoice-calculator/lib/utils"
 	"encoding/json"
 	"fmt"
@@ -71,14 +73,39 @@ type ConsumptionAPIResponse struct {
 	Result []ResultItem `json:"result"`
 }
 
+// HourlyConsumption is the consumption of one metering interval.
+// Hourly settled meters have one-hour intervals; flex-settled meters may deliver quarter-hours.
 type HourlyConsumption struct {
-	DateTime    time.Time
+	DateTime    time.Time     // start of the interval
+	Resolution  time.Duration // length of the interval
 	Consumption float64
 	Quality     string
 }
 
-func GetConsumptionData(refreshToken, meterPointId string, startDate, endDate time.Time) (*ConsumptionAPIResponse, error) {
-	url := APIEndpoint + "meterdata/gettimeseries/" + startDate.Format("2006-01-02") + "/" + endDate.Format("2006-01-02") + "/Hour"
+// Aggregations accepted by the time series endpoint
+const (
+	AggregationActual  = "Actual"  // the resolution the meter is settled in
+	AggregationQuarter = "Quarter" // 15-minute intervals (PT15M)
+	AggregationHour    = "Hour"    // hourly intervals (PT1H)
+)
+
+// ParseResolution converts the ISO 8601 resolution of a time series period to a duration.
+// Only the resolutions used for metering intervals are supported.
+func ParseResolution(resolution string) (time.Duration, error) {
+	switch resolution {
+	case "PT15M":
+		return 15 * time.Minute, nil
+	case "PT1H", "PT60M":
+		return time.Hour, nil
+	default:
+		return 0, fmt.Errorf("unsupported resolution %q", resolution)
+	}
+}
+
+// GetConsumptionData gets the time series of a meter point in the given aggregation,
+// e.g. AggregationHour or AggregationQuarter
+func (c *Client) GetConsumptionData(ctx context.Context, meterPointId string, startDate, endDate time.Time, aggregation string) (*ConsumptionAPIResponse, error) {
+	url := c.BaseURL + "meterdata/gettimeseries/" + startDate.Format("2006-01-02") + "/" + endDate.Format("2006-01-02") + "/" + aggregation
 
 	body := []byte(fmt.Sprintf(`{
 		"meteringPoints": {
@@ -86,13 +113,9 @@ func GetConsumptionData(refreshToken, meterPointId string, startDate, endDate ti
 		}
 	}`, meterPointId))
 
-	response, err := utils.MakeRequestWithToken("POST", url, refreshToken, body)
+	response, err := c.request(ctx, "POST", url, body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get consumption data: %v", err)
-	}
-
-	if err = utils.ValidateStatusOK(response); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get consumption data: %w", err)
 	}
 
 	var apiResponse ConsumptionAPIResponse
@@ -104,7 +127,8 @@ func GetConsumptionData(refreshToken, meterPointId string, startDate, endDate ti
 	return &apiResponse, nil
 }
 
-// ProcessConsumptionData converts raw API response to structured hourly consumption data
+// ProcessConsumptionData converts raw API response to structured consumption data,
+// one entry per interval in the resolution of each period
 func ProcessConsumptionData(response *ConsumptionAPIResponse) ([]HourlyConsumption, error) {
 	if len(response.Result) == 0 {
 		return nil, fmt.Errorf("no consumption data found in response")
@@ -134,6 +158,11 @@ func ProcessConsumptionData(response *ConsumptionAPIResponse) ([]HourlyConsumpti
 			return nil, fmt.Errorf("failed to parse period start time: %v", err)
 		}
 
+		resolution, err := ParseResolution(period.Resolution)
+		if err != nil {
+			return nil, fmt.Errorf("period starting %s: %v", period.TimeInterval.Start, err)
+		}
+
 		for _, point := range period.Point {
 			// Parse position as integer
 			position, err := strconv.Atoi(point.Position)
@@ -147,12 +176,13 @@ func ProcessConsumptionData(response *ConsumptionAPIResponse) ([]HourlyConsumpti
 				return nil, fmt.Errorf("failed to parse quantity: %v", err)
 			}
 
-			// Calculate the actual datetime for this hour
-			// Position 1 = startTime, Position 2 = startTime + 1 hour, etc.
-			hourDateTime := startTime.Add(time.Duration(position-1) * time.Hour)
+			// Calculate the actual datetime for this interval
+			// Position 1 = startTime, Position 2 = startTime + 1 resolution, etc.
+			intervalDateTime := startTime.Add(time.Duration(position-1) * resolution)
 
 			hourlyConsumption := HourlyConsumption{
-				DateTime:    hourDateTime,
+				DateTime:    intervalDateTime,
+				Resolution:  resolution,
 				Consumption: quantity,
 				Quality:     point.Quality,
 			}
@@ -164,19 +194,31 @@ func ProcessConsumptionData(response *ConsumptionAPIResponse) ([]HourlyConsumpti
 	return hourlyConsumptions, nil
 }
 
-// GetConsumptionForPeriod is a convenience function that fetches and processes consumption data
-func GetConsumptionForPeriod(refreshToken, meterPointId string, startDate, endDate time.Time) ([]HourlyConsumption, error) {
-	response, err := GetConsumptionData(refreshToken, meterPointId, startDate, endDate)
-	if err != nil {
-		return nil, err
+// Grid operators may correct metered readings for some weeks after the day, e.g. after
+// estimating readings of a meter that did not report. Cached days this recent are fetched again.
+const RevisionWindow = 30 * 24 * time.Hour
+
+// GetConsumptionForPeriod is a convenience function that fetches and processes consumption data.
+// Days already complete in c.Cache are not requested again, unless they were fetched within
+// RevisionWindow of the day and may have been corrected since; days fetched from the API are stored.
+// If c.Cache is offline and hours are missing, the cached data is returned with a *cache.MissingError.
+func (c *Client) GetConsumptionForPeriod(ctx context.Context, meterPointId string, startDate, endDate time.Time, aggregation string) ([]HourlyConsumption, error) {
+	series := cache.Series[HourlyConsumption]{
+		Name:   fmt.Sprintf("consumption/%s/%s", meterPointId, aggregation),
+		TimeOf: func(hourly HourlyConsumption) time.Time { return hourly.DateTime },
+		Length: func(hourly HourlyConsumption) time.Duration { return hourly.Resolution },
+
+		RevisionWindow: RevisionWindow,
 	}
 
-	hourlyConsumptions, err := ProcessConsumptionData(response)
-	if err != nil {
-		return nil, err
-	}
+	return cache.Fetch(ctx, c.Cache, series, startDate, endDate, func(ctx context.Context, start, end time.Time) ([]HourlyConsumption, error) {
+		response, err := c.GetConsumptionData(ctx, meterPointId, start, end, aggregation)
+		if err != nil {
+			return nil, err
+		}
 
-	return hourlyConsumptions, nil
+		return ProcessConsumptionData(response)
+	})
 }
 
 // GetTotalConsumption calculates total consumption for a period
@@ -188,12 +230,49 @@ func GetTotalConsumption(hourlyConsumptions []HourlyConsumption) float64 {
 	return total
 }
 
-// GetConsumptionByHour returns consumption data grouped by hour of day (0-23)
+// FinestResolution returns the shortest interval in the consumption data, or one hour if there is none
+func FinestResolution(hourlyConsumptions []HourlyConsumption) time.Duration {
+	finest := time.Hour
+	for _, hourly := range hourlyConsumptions {
+		if hourly.Resolution > 0 && hourly.Resolution < finest {
+			finest = hourly.Resolution
+		}
+	}
+	return finest
+}
+
+// SumByHour adds up sub-hourly intervals to hourly consumption. Hourly data is returned unchanged.
+func SumByHour(hourlyConsumptions []HourlyConsumption) []HourlyConsumption {
+	var hourly []HourlyConsumption
+	indexByHour := make(map[time.Time]int)
+
+	for _, interval := range hourlyConsumptions {
+		hour := interval.DateTime.Truncate(time.Hour)
+		if i, exists := indexByHour[hour]; exists {
+			hourly[i].Consumption += interval.Consumption
+			continue
+		}
+
+		indexByHour[hour] = len(hourly)
+		hourly = append(hourly, HourlyConsumption{
+			DateTime:    hour,
+			Resolution:  time.Hour,
+			Consumption: interval.Consumption,
+			Quality:     interval.Quality,
+		})
+	}
+
+	return hourly
+}
+
+// GetConsumptionByHour returns hourly consumption grouped by Copenhagen hour of day (0-23).
+// Both hours of the 25-hour day at the end of summer time are counted in hour 2.
 func GetConsumptionByHour(hourlyConsumptions []HourlyConsumption) map[int][]float64 {
 	hourlyMap := make(map[int][]float64)
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
 
-	for _, hourly := range hourlyConsumptions {
-		hour := hourly.DateTime.Hour()
+	for _, hourly := range SumByHour(hourlyConsumptions) {
+		hour := hourly.DateTime.In(copenhagen).Hour()
 		hourlyMap[hour] = append(hourlyMap[hour], hourly.Consumption)
 	}
 
@@ -207,7 +286,7 @@ func FormatConsumptionSummary(hourlyConsumptions []HourlyConsumption) string {
 	}
 
 	totalConsumption := GetTotalConsumption(hourlyConsumptions)
-	totalHours := len(hourlyConsumptions)
+	totalHours := len(SumByHour(hourlyConsumptions))
 	avgHourly := totalConsumption / float64(totalHours)
 
 	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
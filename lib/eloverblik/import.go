@@ -0,0 +1,302 @@
+package eloverblik
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Column names of the "Måledata" export from the Eloverblik website
+const (
+	columnMeterPoint = "Målepunkt id"
+	columnFrom       = "Fra_dato"
+	columnTo         = "Til_dato"
+	columnQuantity   = "Mængde"
+	columnQuality    = "Kvalitet"
+)
+
+// Date layouts seen in the exports. Times are Copenhagen local time.
+var exportDateLayouts = []string{
+	"02-01-2006 15:04:05",
+	"02-01-2006 15:04",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+}
+
+// ImportConsumptionFile reads a "Måledata" export downloaded from the Eloverblik website,
+// either the CSV file or the Excel (.xlsx) file. If the export holds several meter points,
+// meterPointId selects one of them; it may be empty for an export of a single meter point.
+func ImportConsumptionFile(filename, meterPointId string) ([]HourlyConsumption, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %v", filename, err)
+	}
+
+	var rows [][]string
+	if strings.EqualFold(filepath.Ext(filename), ".xlsx") {
+		rows, err = readXLSXRows(data)
+	} else {
+		rows, err = readCSVRows(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %v", filename, err)
+	}
+
+	consumption, err := ParseMeasurementRows(rows, meterPointId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	return consumption, nil
+}
+
+// ParseMeasurementRows converts the rows of a "Måledata" export, header row first, to consumption data.
+// Rows without a quantity are skipped.
+func ParseMeasurementRows(rows [][]string, meterPointId string) ([]HourlyConsumption, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("export is empty")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	column := func(name string) int {
+		if i, exists := columns[strings.ToLower(name)]; exists {
+			return i
+		}
+		return -1
+	}
+
+	fromColumn, toColumn := column(columnFrom), column(columnTo)
+	quantityColumn, qualityColumn := column(columnQuantity), column(columnQuality)
+	meterPointColumn := column(columnMeterPoint)
+	if fromColumn < 0 || quantityColumn < 0 {
+		return nil, fmt.Errorf("export has no %s and %s columns (found %s)", columnFrom, columnQuantity, strings.Join(rows[0], ", "))
+	}
+
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+
+	var consumption []HourlyConsumption
+	exportMeterPoint := ""
+	for lineNumber, row := range rows[1:] {
+		line := lineNumber + 2
+		cell := func(i int) string {
+			if i < 0 || i >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+
+		if cell(fromColumn) == "" && cell(quantityColumn) == "" {
+			continue
+		}
+
+		if id := cell(meterPointColumn); id != "" {
+			if meterPointId != "" && id != meterPointId {
+				continue
+			}
+			if exportMeterPoint != "" && id != exportMeterPoint {
+				return nil, fmt.Errorf("export holds several meter points (%s, %s); select one", exportMeterPoint, id)
+			}
+			exportMeterPoint = id
+		}
+
+		if cell(quantityColumn) == "" {
+			continue
+		}
+		quantity, err := parseDanishNumber(cell(quantityColumn))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid %s %q", line, columnQuantity, cell(quantityColumn))
+		}
+
+		// Wall clock times give the resolution; the start in Copenhagen time gives the instant
+		fromWallClock, err := parseExportDate(cell(fromColumn), time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid %s: %v", line, columnFrom, err)
+		}
+		resolution := time.Hour
+		if cell(toColumn) != "" {
+			toWallClock, err := parseExportDate(cell(toColumn), time.UTC)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s: %v", line, columnTo, err)
+			}
+			if toWallClock.Sub(fromWallClock) == 15*time.Minute {
+				resolution = 15 * time.Minute
+			}
+		}
+
+		// The hour repeated when summer time ends has the same local time twice.
+		// Take the first occurrence unless the previous row already did.
+		start, _ := parseExportDate(cell(fromColumn), copenhagen)
+		if earlier := start.Add(-time.Hour); earlier.In(copenhagen).Hour() == start.In(copenhagen).Hour() {
+			if n := len(consumption); n == 0 || earlier.After(consumption[n-1].DateTime) {
+				start = earlier
+			}
+		}
+
+		consumption = append(consumption, HourlyConsumption{
+			DateTime:    start.UTC(),
+			Resolution:  resolution,
+			Consumption: quantity,
+			Quality:     cell(qualityColumn),
+		})
+	}
+
+	if len(consumption) == 0 {
+		if meterPointId != "" {
+			return nil, fmt.Errorf("export has no consumption for meter point %s", meterPointId)
+		}
+		return nil, fmt.Errorf("export has no consumption")
+	}
+
+	return consumption, nil
+}
+
+// parseExportDate parses a date in one of the export layouts, or an Excel date serial number
+func parseExportDate(value string, loc *time.Location) (time.Time, error) {
+	for _, layout := range exportDateLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	// Excel stores dates as days since 1899-12-30
+	if serial, err := strconv.ParseFloat(value, 64); err == nil {
+		seconds := math.Round(serial * 24 * 60 * 60)
+		wallClock := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).Add(time.Duration(seconds) * time.Second)
+		return time.Date(wallClock.Year(), wallClock.Month(), wallClock.Day(), wallClock.Hour(), wallClock.Minute(), wallClock.Second(), 0, loc), nil
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognised date %q", value)
+}
+
+// parseDanishNumber parses numbers like 0,123 or 1.234,5 as well as 0.123
+func parseDanishNumber(value string) (float64, error) {
+	if strings.Contains(value, ",") {
+		value = strings.ReplaceAll(value, ".", "")
+		value = strings.ReplaceAll(value, ",", ".")
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// readCSVRows reads a semicolon-separated file
+func readCSVRows(data []byte) ([][]string, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = ';'
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	return reader.ReadAll()
+}
+
+// readXLSXRows reads the cell values of the first worksheet of an Excel workbook
+func readXLSXRows(data []byte) ([][]string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not an Excel workbook: %v", err)
+	}
+
+	var sharedStrings struct {
+		Items []struct {
+			Text string `xml:"t"`
+			Runs []struct {
+				Text string `xml:"t"`
+			} `xml:"r"`
+		} `xml:"si"`
+	}
+	if err = decodeZipXML(archive, "xl/sharedStrings.xml", &sharedStrings); err != nil && err != os.ErrNotExist {
+		return nil, err
+	}
+	strs := make([]string, len(sharedStrings.Items))
+	for i, item := range sharedStrings.Items {
+		strs[i] = item.Text
+		for _, run := range item.Runs {
+			strs[i] += run.Text
+		}
+	}
+
+	var sheet struct {
+		Rows []struct {
+			Cells []struct {
+				Ref    string `xml:"r,attr"`
+				Type   string `xml:"t,attr"`
+				Value  string `xml:"v"`
+				Inline string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err = decodeZipXML(archive, "xl/worksheets/sheet1.xml", &sheet); err != nil {
+		if err == os.ErrNotExist {
+			return nil, fmt.Errorf("workbook has no first worksheet")
+		}
+		return nil, err
+	}
+
+	rows := make([][]string, 0, len(sheet.Rows))
+	for _, sheetRow := range sheet.Rows {
+		var row []string
+		for _, c := range sheetRow.Cells {
+			value := c.Value
+			switch c.Type {
+			case "s":
+				index, err := strconv.Atoi(c.Value)
+				if err != nil || index < 0 || index >= len(strs) {
+					return nil, fmt.Errorf("cell %s refers to unknown shared string %q", c.Ref, c.Value)
+				}
+				value = strs[index]
+			case "inlineStr":
+				value = c.Inline
+			}
+
+			// Place the value in its column, as empty cells are left out
+			columnIndex := len(row)
+			if c.Ref != "" {
+				columnIndex = xlsxColumnIndex(c.Ref)
+			}
+			for len(row) < columnIndex {
+				row = append(row, "")
+			}
+			row = append(row, value)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// decodeZipXML decodes an XML file in a zip archive. It returns os.ErrNotExist if the file is missing.
+func decodeZipXML(archive *zip.Reader, name string, v any) error {
+	file, err := archive.Open(name)
+	if err != nil {
+		return os.ErrNotExist
+	}
+	defer file.Close()
+
+	if err = xml.NewDecoder(file).Decode(v); err != nil {
+		return fmt.Errorf("could not parse %s: %v", name, err)
+	}
+	return nil
+}
+
+// xlsxColumnIndex converts the column letters of a cell reference such as "C7" to a zero-based index
+func xlsxColumnIndex(ref string) int {
+	index := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		index = index*26 + int(r-'A'+1)
+	}
+	return index - 1
+}
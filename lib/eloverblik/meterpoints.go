@@ -1,11 +1,13 @@
 package eloverblik
 
 import (
-	"electricity-invoice-calculator/lib/utils"
+	"context"
+	"electricity-invoice-calculator/lib/cache"
 	"encoding/json"
 	"fmt"
 )
 
+// Default base URL of the Eloverblik customer API, used by NewClient
 var APIEndpoint string = "https://api.eloverblik.dk/customerapi/api/"
 
 type MeterPoint struct {
@@ -38,17 +40,19 @@ type DetailedAPIResponse struct {
 	} `json:"result"`
 }
 
-// Requests meter points and returns respnse as list of MeterPoint
-func GetMeterPoints(refreshToken string) ([]MeterPoint, error) {
-	url := APIEndpoint + "meteringpoints/meteringpoints"
+// Requests meter points and returns respnse as list of MeterPoint.
+// The response is snapshotted in c.Cache for offline runs.
+func (c *Client) GetMeterPoints(ctx context.Context) ([]MeterPoint, error) {
+	return cache.Snapshot(ctx, c.Cache, "meterpoints", c.fetchMeterPoints)
+}
 
-	response, err := utils.MakeRequestWithToken("GET", url, refreshToken, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get meter points: %v", err)
-	}
+// fetchMeterPoints requests the meter points from the API
+func (c *Client) fetchMeterPoints(ctx context.Context) ([]MeterPoint, error) {
+	url := c.BaseURL + "meteringpoints/meteringpoints"
 
-	if err = utils.ValidateStatusOK(response); err != nil {
-		return nil, err
+	response, err := c.request(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get meter points: %w", err)
 	}
 
 	var apiResponse APIResponse
@@ -61,22 +65,26 @@ func GetMeterPoints(refreshToken string) ([]MeterPoint, error) {
 }
 
 // Requests for Meter Point (extra) details
-// and returns Grid Operator details as MeterPointDetails
-func GetMeterPointDetails(refreshToken, meterPointId string) (MeterPointDetails, error) {
-	url := APIEndpoint + "meteringpoints/meteringpoint/getdetails"
+// and returns Grid Operator details as MeterPointDetails.
+// The response is snapshotted in c.Cache for offline runs.
+func (c *Client) GetMeterPointDetails(ctx context.Context, meterPointId string) (MeterPointDetails, error) {
+	return cache.Snapshot(ctx, c.Cache, "details/"+meterPointId, func(ctx context.Context) (MeterPointDetails, error) {
+		return c.fetchMeterPointDetails(ctx, meterPointId)
+	})
+}
+
+// fetchMeterPointDetails requests the details of a meter point from the API
+func (c *Client) fetchMeterPointDetails(ctx context.Context, meterPointId string) (MeterPointDetails, error) {
+	url := c.BaseURL + "meteringpoints/meteringpoint/getdetails"
 
 	body := []byte(fmt.Sprintf(`{
 		"meteringPoints": {
 			"meteringPoint": ["%s"]
 		}
 	}`, meterPointId))
-	response, err := utils.MakeRequestWithToken("POST", url, refreshToken, body)
+	response, err := c.request(ctx, "POST", url, body)
 	if err != nil {
-		return MeterPointDetails{}, fmt.Errorf("failed to get meter point grid operator: %v", err)
-	}
-
-	if err = utils.ValidateStatusOK(response); err != nil {
-		return MeterPointDetails{}, err
+		return MeterPointDetails{}, fmt.Errorf("failed to get meter point grid operator: %w", err)
 	}
 
 	var apiResponse DetailedAPIResponse
@@ -0,0 +1,130 @@
+package eloverblik
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Tokens expiring within this margin are treated as already expired
+const tokenExpiryMargin = 5 * time.Minute
+
+// CachedToken is a data access token stored on disk between runs
+type CachedToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	JWTHash   string    `json:"jwtHash"` // hash of the JWT token the data access token was minted from
+	BaseURL   string    `json:"baseURL"` // API the data access token was minted by
+}
+
+// Returns the default token cache file in the user config directory
+func DefaultTokenCachePath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("could not find user config directory: %v", err)
+	}
+
+	return filepath.Join(configDir, "electricity-invoice-calculator", "token.json"), nil
+}
+
+// Decodes the expiry time (exp claim) of a JWT without verifying its signature
+func TokenExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("token is not a JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not decode JWT payload: %v", err)
+	}
+
+	var claims struct {
+		Exp json.Number `json:"exp"`
+	}
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("could not parse JWT payload: %v", err)
+	}
+
+	exp, err := claims.Exp.Float64()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("JWT has no valid exp claim: %v", err)
+	}
+
+	return time.Unix(int64(exp), 0), nil
+}
+
+// Reads a cached data access token minted from jwtToken by the API at baseURL.
+// Returns an empty string if no usable token is cached.
+func LoadCachedToken(cachePath, baseURL, jwtToken string) (string, error) {
+	data, err := os.ReadFile(cachePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not read %s: %v", cachePath, err)
+	}
+
+	var cached CachedToken
+	if err = json.Unmarshal(data, &cached); err != nil {
+		// A corrupt cache is not fatal, a new token is simply minted
+		return "", nil
+	}
+
+	if cached.JWTHash != hashJWT(jwtToken) || cached.BaseURL != baseURL || time.Now().Add(tokenExpiryMargin).After(cached.ExpiresAt) {
+		return "", nil
+	}
+
+	return cached.Token, nil
+}
+
+// Writes a data access token minted by the API at baseURL to the cache, readable only by the current user
+func SaveCachedToken(cachePath, baseURL, jwtToken, token string) error {
+	expiresAt, err := TokenExpiry(token)
+	if err != nil {
+		// Data access tokens are documented to be valid for 24 hours
+		expiresAt = time.Now().Add(24 * time.Hour)
+	}
+
+	data, err := json.MarshalIndent(CachedToken{
+		Token:     token,
+		ExpiresAt: expiresAt,
+		JWTHash:   hashJWT(jwtToken),
+		BaseURL:   baseURL,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode token cache: %v", err)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(cachePath), 0700); err != nil {
+		return fmt.Errorf("could not create %s: %v", filepath.Dir(cachePath), err)
+	}
+
+	if err = os.WriteFile(cachePath, data, 0600); err != nil {
+		return fmt.Errorf("could not write %s: %v", cachePath, err)
+	}
+
+	return nil
+}
+
+// Removes the cached data access token, e.g. after the API rejected it
+func InvalidateCachedToken(cachePath string) error {
+	err := os.Remove(cachePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not remove %s: %v", cachePath, err)
+	}
+	return nil
+}
+
+// hashJWT identifies the JWT a cached token belongs to without storing the JWT itself
+func hashJWT(jwtToken string) string {
+	sum := sha256.Sum256([]byte(jwtToken))
+	return hex.EncodeToString(sum[:])
+}
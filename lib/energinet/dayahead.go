@@ -0,0 +1,111 @@
+package energinet
+
+import (
+	"context"
+	"electricity-invoice-calculator/lib/utils"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DayAheadCutover is the first delivery hour priced in 15-minute intervals in the Nordic
+// day-ahead market (2025-10-01 00:00 Copenhagen time). Elspotprices has no data from here on;
+// DayAheadPrices must be used instead.
+var DayAheadCutover = time.Date(2025, 9, 30, 22, 0, 0, 0, time.UTC)
+
+// Layout of TimeUTC/TimeDK in DayAheadPrices and HourUTC/HourDK in Elspotprices
+const RecordTimeLayout = "2006-01-02T15:04:05"
+
+type DayAheadPriceRecord struct {
+	TimeUTC          string  `json:"TimeUTC"`
+	TimeDK           string  `json:"TimeDK"`
+	PriceArea        string  `json:"PriceArea"`
+	DayAheadPriceEUR float64 `json:"DayAheadPriceEUR"`
+	DayAheadPriceDKK float64 `json:"DayAheadPriceDKK"`
+}
+
+type DayAheadAPIResponse struct {
+	Total   int                   `json:"total"`
+	Limit   int                   `json:"limit"`
+	Dataset string                `json:"dataset"`
+	Records []DayAheadPriceRecord `json:"records"`
+}
+
+// Gets 15-minute day-ahead prices from the DayAheadPrices dataset
+func (c *Client) GetDayAheadPrices(ctx context.Context, startDate, endDate string, priceAreas []string) ([]DayAheadPriceRecord, error) {
+	apiURL := c.buildURL("DayAheadPrices", "TimeUTC", startDate, endDate, priceAreas)
+
+	response, err := c.HTTP.MakeRequest(ctx, "GET", apiURL, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = utils.ValidateStatusOK(response); err != nil {
+		return nil, err
+	}
+
+	var apiResp DayAheadAPIResponse
+	err = json.Unmarshal(response.Body, &apiResp)
+	if err != nil {
+		return nil, fmt.Errorf("JSON parsing failed: %v", err)
+	}
+
+	return apiResp.Records, nil
+}
+
+// Averages 15-minute day-ahead prices per interval of the given resolution and price area into
+// spot price records. Hourly settled consumption is priced at the hourly average; with a
+// 15-minute resolution every quarter-hour keeps its own price and HourUTC/HourDK hold the
+// start of the quarter-hour.
+func AggregateDayAheadPrices(records []DayAheadPriceRecord, resolution time.Duration) ([]SpotPriceRecord, error) {
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+
+	type hourKey struct {
+		hour      time.Time
+		priceArea string
+	}
+	type hourSum struct {
+		dkk, eur float64
+		count    int
+	}
+
+	sums := make(map[hourKey]*hourSum)
+	for _, record := range records {
+		timeUTC, err := time.ParseInLocation(RecordTimeLayout, record.TimeUTC, time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse TimeUTC %q: %v", record.TimeUTC, err)
+		}
+
+		key := hourKey{hour: timeUTC.Truncate(resolution), priceArea: record.PriceArea}
+		sum, exists := sums[key]
+		if !exists {
+			sum = &hourSum{}
+			sums[key] = sum
+		}
+		sum.dkk += record.DayAheadPriceDKK
+		sum.eur += record.DayAheadPriceEUR
+		sum.count++
+	}
+
+	hourly := make([]SpotPriceRecord, 0, len(sums))
+	for key, sum := range sums {
+		hourly = append(hourly, SpotPriceRecord{
+			HourUTC:      key.hour.Format(RecordTimeLayout),
+			HourDK:       key.hour.In(copenhagen).Format(RecordTimeLayout),
+			PriceArea:    key.priceArea,
+			SpotPriceDKK: sum.dkk / float64(sum.count),
+			SpotPriceEUR: sum.eur / float64(sum.count),
+		})
+	}
+
+	// Newest first, matching the order of Elspotprices
+	sort.Slice(hourly, func(i, j int) bool {
+		if hourly[i].HourUTC != hourly[j].HourUTC {
+			return hourly[i].HourUTC > hourly[j].HourUTC
+		}
+		return hourly[i].PriceArea < hourly[j].PriceArea
+	})
+
+	return hourly, nil
+}
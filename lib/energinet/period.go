@@ -0,0 +1,97 @@
+package energinet
+
+import (
+	"context"
+	"electricity-invoice-calculator/lib/cache"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// GetSpotPricesForPeriod gets the spot prices of [startDate, endDate) for one price area.
+// Hours before DayAheadCutover come from the hourly Elspotprices dataset; later prices come
+// from the 15-minute DayAheadPrices dataset, averaged per resolution (time.Hour or 15 minutes).
+// Days already in c.Cache are not requested again. Records are sorted newest first, like the API.
+// If c.Cache is offline and hours are missing, the cached prices are returned with a *cache.MissingError.
+func (c *Client) GetSpotPricesForPeriod(ctx context.Context, startDate, endDate time.Time, priceArea string, resolution time.Duration) ([]SpotPriceRecord, error) {
+	series := cache.Series[SpotPriceRecord]{
+		Name:   fmt.Sprintf("spotprices/%s/%dmin", priceArea, int(resolution.Minutes())),
+		TimeOf: spotPriceTime,
+		Length: func(record SpotPriceRecord) time.Duration {
+			// Hourly before the cutover, in resolution after it
+			if spotPriceTime(record).Before(DayAheadCutover) {
+				return time.Hour
+			}
+			return resolution
+		},
+	}
+
+	spotPrices, err := cache.Fetch(ctx, c.Cache, series, startDate, endDate, func(ctx context.Context, start, end time.Time) ([]SpotPriceRecord, error) {
+		return c.fetchSpotPrices(ctx, start, end, priceArea, resolution)
+	})
+
+	sort.SliceStable(spotPrices, func(i, j int) bool {
+		return spotPrices[i].HourUTC > spotPrices[j].HourUTC
+	})
+
+	// An offline cache returns the prices it has together with a *cache.MissingError
+	return spotPrices, err
+}
+
+// fetchSpotPrices requests [startDate, endDate) from Elspotprices and/or DayAheadPrices,
+// depending on which side of DayAheadCutover the period is
+func (c *Client) fetchSpotPrices(ctx context.Context, startDate, endDate time.Time, priceArea string, resolution time.Duration) ([]SpotPriceRecord, error) {
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+
+	// Format dates for the API
+	startDateStr := startDate.In(copenhagen).Format("2006-01-02")
+	endDateStr := endDate.In(copenhagen).Format("2006-01-02")
+	cutoverStr := DayAheadCutover.In(copenhagen).Format("2006-01-02")
+
+	var spotPrices []SpotPriceRecord
+
+	// Part of the period before the switch to 15-minute prices
+	if startDate.Before(DayAheadCutover) {
+		elspotEnd := endDateStr
+		if endDate.After(DayAheadCutover) {
+			elspotEnd = cutoverStr
+		}
+
+		elspotPrices, err := c.GetSpotPrices(ctx, startDateStr, elspotEnd, []string{priceArea})
+		if err != nil {
+			return nil, err
+		}
+		spotPrices = append(spotPrices, elspotPrices...)
+	}
+
+	// Part of the period from the switch on
+	if endDate.After(DayAheadCutover) {
+		dayAheadStart := startDateStr
+		if startDate.Before(DayAheadCutover) {
+			dayAheadStart = cutoverStr
+		}
+
+		dayAheadPrices, err := c.GetDayAheadPrices(ctx, dayAheadStart, endDateStr, []string{priceArea})
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch day-ahead prices: %v", err)
+		}
+
+		aggregatedPrices, err := AggregateDayAheadPrices(dayAheadPrices, resolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to aggregate day-ahead prices: %v", err)
+		}
+		spotPrices = append(spotPrices, aggregatedPrices...)
+	}
+
+	return spotPrices, nil
+}
+
+// spotPriceTime returns the start of the interval a spot price record covers
+func spotPriceTime(record SpotPriceRecord) time.Time {
+	hourUTC, err := time.ParseInLocation(RecordTimeLayout, record.HourUTC, time.UTC)
+	if err != nil {
+		// Unparseable records sort first and fall outside every period
+		return time.Time{}
+	}
+	return hourUTC
+}
@@ -0,0 +1,215 @@
+package energinet
+
+import (
+	"context"
+	"crypto/sha256"
+	"electricity-invoice-calculator/lib/cache"
+	"electricity-invoice-calculator/lib/utils"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Energinet's GLN number and charge codes for the national tariffs
+const (
+	EnerginetGLN           = "5790000432752"
+	SystemTariffCode       = "41000"  // Systemtarif
+	TransmissionTariffCode = "40000"  // Transmissions nettarif
+	ElectricityTaxCode     = "EA-001" // Elafgift
+	TariffChargeType       = "D03"    // ChargeType of tariffs
+)
+
+// Layout of ValidFrom/ValidTo in DatahubPricelist (Copenhagen local time)
+const PricelistDateLayout = "2006-01-02T15:04:05"
+
+// PricelistRecord is a single charge version from the DatahubPricelist dataset.
+// Prices are in DKK/kWh; Price2-Price24 are nil when the price is the same all day.
+type PricelistRecord struct {
+	ChargeOwner          string   `json:"ChargeOwner"`
+	GLNNumber            string   `json:"GLN_Number"`
+	ChargeType           string   `json:"ChargeType"`
+	ChargeTypeCode       string   `json:"ChargeTypeCode"`
+	Note                 string   `json:"Note"`
+	Description          string   `json:"Description"`
+	ValidFrom            string   `json:"ValidFrom"`
+	ValidTo              *string  `json:"ValidTo"`
+	VATClass             string   `json:"VATClass"`
+	Price1               *float64 `json:"Price1"`
+	Price2               *float64 `json:"Price2"`
+	Price3               *float64 `json:"Price3"`
+	Price4               *float64 `json:"Price4"`
+	Price5               *float64 `json:"Price5"`
+	Price6               *float64 `json:"Price6"`
+	Price7               *float64 `json:"Price7"`
+	Price8               *float64 `json:"Price8"`
+	Price9               *float64 `json:"Price9"`
+	Price10              *float64 `json:"Price10"`
+	Price11              *float64 `json:"Price11"`
+	Price12              *float64 `json:"Price12"`
+	Price13              *float64 `json:"Price13"`
+	Price14              *float64 `json:"Price14"`
+	Price15              *float64 `json:"Price15"`
+	Price16              *float64 `json:"Price16"`
+	Price17              *float64 `json:"Price17"`
+	Price18              *float64 `json:"Price18"`
+	Price19              *float64 `json:"Price19"`
+	Price20              *float64 `json:"Price20"`
+	Price21              *float64 `json:"Price21"`
+	Price22              *float64 `json:"Price22"`
+	Price23              *float64 `json:"Price23"`
+	Price24              *float64 `json:"Price24"`
+	TransparentInvoicing int      `json:"TransparentInvoicing"`
+	TaxIndicator         int      `json:"TaxIndicator"`
+	ResolutionDuration   string   `json:"ResolutionDuration"`
+}
+
+// PricelistAPIResponse represents the DatahubPricelist API response
+type PricelistAPIResponse struct {
+	Total   int               `json:"total"`
+	Limit   int               `json:"limit"`
+	Dataset string            `json:"dataset"`
+	Records []PricelistRecord `json:"records"`
+}
+
+// PricelistFilter selects charges in DatahubPricelist. Empty fields do not filter.
+type PricelistFilter struct {
+	GLNNumber       string   // charge owner, e.g. the grid operator's GLN
+	ChargeTypeCodes []string // e.g. SystemTariffCode
+	Notes           []string // charge names as shown on the bill, e.g. "Nettarif C time"
+	ChargeType      string   // e.g. TariffChargeType
+}
+
+// HourlyPrices returns the 24 prices of the record by position (index 0 = hour 00-01).
+// Missing positions use Price1, as DataHub leaves them empty for flat prices.
+func (r PricelistRecord) HourlyPrices() []float64 {
+	positions := []*float64{
+		r.Price1, r.Price2, r.Price3, r.Price4, r.Price5, r.Price6,
+		r.Price7, r.Price8, r.Price9, r.Price10, r.Price11, r.Price12,
+		r.Price13, r.Price14, r.Price15, r.Price16, r.Price17, r.Price18,
+		r.Price19, r.Price20, r.Price21, r.Price22, r.Price23, r.Price24,
+	}
+
+	var flatPrice float64
+	if r.Price1 != nil {
+		flatPrice = *r.Price1
+	}
+
+	prices := make([]float64, len(positions))
+	for i, price := range positions {
+		if price != nil {
+			prices[i] = *price
+		} else {
+			prices[i] = flatPrice
+		}
+	}
+
+	return prices
+}
+
+// IsFlat reports whether the record has one price for the whole day
+func (r PricelistRecord) IsFlat() bool {
+	if r.ResolutionDuration == "P1D" {
+		return true
+	}
+
+	prices := r.HourlyPrices()
+	for _, price := range prices[1:] {
+		if price != prices[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// IsValidAt reports whether the record applies at the given time.
+// The interval is [ValidFrom, ValidTo); a missing ValidTo means open-ended.
+func (r PricelistRecord) IsValidAt(at time.Time) bool {
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+
+	validFrom, err := time.ParseInLocation(PricelistDateLayout, r.ValidFrom, copenhagen)
+	if err != nil || at.Before(validFrom) {
+		return false
+	}
+
+	if r.ValidTo != nil && *r.ValidTo != "" {
+		validTo, err := time.ParseInLocation(PricelistDateLayout, *r.ValidTo, copenhagen)
+		if err == nil && !at.Before(validTo) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Gets all charge versions matching filter that are valid at some point in [startDate, endDate).
+// Times in the dataset are Copenhagen local time. Responses are snapshotted in c.Cache for offline runs.
+func (c *Client) GetDatahubPricelist(ctx context.Context, startDate, endDate time.Time, filter PricelistFilter) ([]PricelistRecord, error) {
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+
+	// start/end filter on ValidFrom, so only the upper bound can be applied server side:
+	// a version valid from years ago may still apply to the requested period.
+	params := url.Values{}
+	params.Add("offset", "0")
+	params.Add("limit", "0")
+	params.Add("end", endDate.In(copenhagen).Format("2006-01-02T15:04"))
+	params.Add("sort", "ValidFrom DESC")
+
+	filterValues := make(map[string][]string)
+	if filter.GLNNumber != "" {
+		filterValues["GLN_Number"] = []string{filter.GLNNumber}
+	}
+	if len(filter.ChargeTypeCodes) > 0 {
+		filterValues["ChargeTypeCode"] = filter.ChargeTypeCodes
+	}
+	if len(filter.Notes) > 0 {
+		filterValues["Note"] = filter.Notes
+	}
+	if filter.ChargeType != "" {
+		filterValues["ChargeType"] = []string{filter.ChargeType}
+	}
+	if len(filterValues) > 0 {
+		filterJSON, err := json.Marshal(filterValues)
+		if err != nil {
+			return nil, fmt.Errorf("could not encode filter: %v", err)
+		}
+		params.Add("filter", string(filterJSON))
+	}
+
+	query := params.Encode()
+	queryHash := sha256.Sum256([]byte(query))
+	allRecords, err := cache.Snapshot(ctx, c.Cache, "pricelist/"+hex.EncodeToString(queryHash[:8]), func(ctx context.Context) ([]PricelistRecord, error) {
+		response, err := c.HTTP.MakeRequest(ctx, "GET", c.BaseURL+"dataset/DatahubPricelist?"+query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = utils.ValidateStatusOK(response); err != nil {
+			return nil, err
+		}
+
+		var apiResp PricelistAPIResponse
+		if err = json.Unmarshal(response.Body, &apiResp); err != nil {
+			return nil, fmt.Errorf("JSON parsing failed: %v", err)
+		}
+		return apiResp.Records, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop versions that ended before the period started
+	var records []PricelistRecord
+	for _, record := range allRecords {
+		if record.ValidTo != nil && *record.ValidTo != "" {
+			validTo, err := time.ParseInLocation(PricelistDateLayout, *record.ValidTo, copenhagen)
+			if err == nil && !validTo.After(startDate) {
+				continue
+			}
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
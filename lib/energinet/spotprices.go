@@ -1,10 +1,11 @@
 package energinet
 
 import (
+	"context"
+	"electricity-invoice-calculator/lib/cache"
+	"electricity-invoice-calculator/lib/utils"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
 	"strings"
 )
@@ -17,6 +18,10 @@ type SpotPriceRecord struct {
 	SpotPriceEUR float64 `json:"SpotPriceEUR"`
 }
 
+// EUR to DKK rate for prices the APIs do not give in both currencies. The krone is pegged
+// to the euro close to this rate, so estimated and converted prices use it as a fixed rate.
+const EURToDKK = 7.45
+
 type APIResponse struct {
 	Total   int               `json:"total"`
 	Limit   int               `json:"limit"`
@@ -24,16 +29,36 @@ type APIResponse struct {
 	Records []SpotPriceRecord `json:"records"`
 }
 
-// Builds URL with correct parameters for API convention
-func buildURL(startDate, endDate string, priceAreas []string) string {
-	baseURL := "https://api.energidataservice.dk/dataset/Elspotprices"
+// Default base URL of the Energi Data Service API, used by NewClient
+var APIEndpoint string = "https://api.energidataservice.dk/"
+
+// Client calls the public Energi Data Service API
+type Client struct {
+	BaseURL string            // base URL of the API, ending in a slash
+	HTTP    *utils.HTTPClient // HTTP client used for all requests
+	Cache   *cache.Store      // local store of spot prices, nil to always request the API
+}
+
+// Creates a client for APIEndpoint using utils.DefaultHTTPClient, without a cache.
+// BaseURL, HTTP and Cache may be replaced before use, e.g. to point at a test server.
+func NewClient() *Client {
+	return &Client{
+		BaseURL: APIEndpoint,
+		HTTP:    utils.DefaultHTTPClient,
+	}
+}
+
+// Builds URL with correct parameters for API convention.
+// Records are sorted newest first on timeColumn.
+func (c *Client) buildURL(dataset, timeColumn, startDate, endDate string, priceAreas []string) string {
+	baseURL := c.BaseURL + "dataset/" + dataset
 
 	params := url.Values{}
 
 	params.Add("offset", "0")
 	params.Add("start", startDate)
 	params.Add("end", endDate)
-	params.Add("sort", "HourUTC DESC")
+	params.Add("sort", timeColumn+" DESC")
 
 	if len(priceAreas) > 0 {
 		areas := strings.Join(priceAreas, `","`)
@@ -45,26 +70,20 @@ func buildURL(startDate, endDate string, priceAreas []string) string {
 }
 
 // Gets spot prices from public Energinet API
-func GetSpotPrices(startDate, endDate string, priceAreas []string) ([]SpotPriceRecord, error) {
-	apiURL := buildURL(startDate, endDate, priceAreas)
+func (c *Client) GetSpotPrices(ctx context.Context, startDate, endDate string, priceAreas []string) ([]SpotPriceRecord, error) {
+	apiURL := c.buildURL("Elspotprices", "HourUTC", startDate, endDate, priceAreas)
 
-	resp, err := http.Get(apiURL)
+	response, err := c.HTTP.MakeRequest(ctx, "GET", apiURL, nil, nil)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %v", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("could not read response: %v", err)
+	if err = utils.ValidateStatusOK(response); err != nil {
+		return nil, err
 	}
 
 	var apiResp APIResponse
-	err = json.Unmarshal(body, &apiResp)
+	err = json.Unmarshal(response.Body, &apiResp)
 	if err != nil {
 		return nil, fmt.Errorf("JSON parsing failed: %v", err)
 	}
@@ -0,0 +1,457 @@
+// Package fakeapi emulates the Eloverblik customer API and the Energi Data Service
+// endpoints used by the calculator, so the complete bill flow can run without network.
+//
+// Meter points, details and charges are served from fixture files. Consumption and
+// spot prices are generated for any requested period from the hourly profiles in
+// profiles.json, so every period has complete data.
+package fakeapi
+
+import (
+	"electricity-invoice-calculator/lib/eloverblik"
+	"electricity-invoice-calculator/lib/energinet"
+	"embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Path prefix of the Eloverblik customer API on the fake server
+const EloverblikPath = "/customerapi/api/"
+
+//go:embed fixtures/*.json
+var embeddedFixtures embed.FS
+
+// Profiles are the hourly shapes used to generate time series, indexed by Copenhagen hour of day
+type Profiles struct {
+	ConsumptionKWh      []float64            `json:"consumptionKWh"`
+	SpotPricesDKKPerMWh map[string][]float64 `json:"spotPricesDKKPerMWh"` // price area -> 24 prices
+}
+
+// Fixtures holds the data served by the fake server
+type Fixtures struct {
+	MeterPoints []byte // response body of meteringpoints/meteringpoints
+	Details     []byte // response body of meteringpoints/meteringpoint/getdetails
+	Charges     []byte // response body of meteringpoints/meteringpoint/getcharges
+	Profiles    Profiles
+	Pricelist   []energinet.PricelistRecord // all DatahubPricelist records
+}
+
+// Loads the fixtures embedded in the package
+func DefaultFixtures() (*Fixtures, error) {
+	fixtures, err := fs.Sub(embeddedFixtures, "fixtures")
+	if err != nil {
+		return nil, err
+	}
+	return LoadFixtures(fixtures)
+}
+
+// Loads fixtures from a directory containing meteringpoints.json, details.json, charges.json,
+// profiles.json and pricelist.json
+func LoadFixturesDir(dir string) (*Fixtures, error) {
+	return LoadFixtures(os.DirFS(dir))
+}
+
+// Loads fixtures from a file system
+func LoadFixtures(fsys fs.FS) (*Fixtures, error) {
+	var fixtures Fixtures
+	var err error
+
+	if fixtures.MeterPoints, err = readJSONFixture(fsys, "meteringpoints.json"); err != nil {
+		return nil, err
+	}
+	if fixtures.Details, err = readJSONFixture(fsys, "details.json"); err != nil {
+		return nil, err
+	}
+	if fixtures.Charges, err = readJSONFixture(fsys, "charges.json"); err != nil {
+		return nil, err
+	}
+
+	profiles, err := readJSONFixture(fsys, "profiles.json")
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(profiles, &fixtures.Profiles); err != nil {
+		return nil, fmt.Errorf("could not parse JSON in profiles.json: %v", err)
+	}
+
+	pricelist, err := readJSONFixture(fsys, "pricelist.json")
+	if err != nil {
+		return nil, err
+	}
+	var pricelistResponse energinet.PricelistAPIResponse
+	if err = json.Unmarshal(pricelist, &pricelistResponse); err != nil {
+		return nil, fmt.Errorf("could not parse JSON in pricelist.json: %v", err)
+	}
+	fixtures.Pricelist = pricelistResponse.Records
+
+	if len(fixtures.Profiles.ConsumptionKWh) != 24 {
+		return nil, fmt.Errorf("profiles.json: consumptionKWh must have 24 values, got %d", len(fixtures.Profiles.ConsumptionKWh))
+	}
+	for area, prices := range fixtures.Profiles.SpotPricesDKKPerMWh {
+		if len(prices) != 24 {
+			return nil, fmt.Errorf("profiles.json: spot prices for %s must have 24 values, got %d", area, len(prices))
+		}
+	}
+
+	return &fixtures, nil
+}
+
+// readJSONFixture reads a fixture file and checks that it is valid JSON
+func readJSONFixture(fsys fs.FS, name string) ([]byte, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("could not read fixture %s: %v", name, err)
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("fixture %s is not valid JSON", name)
+	}
+	return data, nil
+}
+
+// Server serves the fixtures over HTTP
+type Server struct {
+	fixtures *Fixtures
+	mux      *http.ServeMux
+}
+
+// Creates an http.Handler emulating both APIs
+func NewHandler(fixtures *Fixtures) *Server {
+	server := &Server{fixtures: fixtures, mux: http.NewServeMux()}
+
+	server.mux.HandleFunc("GET "+EloverblikPath+"token", server.handleToken)
+	server.mux.HandleFunc("GET "+EloverblikPath+"meteringpoints/meteringpoints", server.authorized(server.serveFixture(fixtures.MeterPoints)))
+	server.mux.HandleFunc("POST "+EloverblikPath+"meteringpoints/meteringpoint/getdetails", server.authorized(server.serveFixture(fixtures.Details)))
+	server.mux.HandleFunc("POST "+EloverblikPath+"meteringpoints/meteringpoint/getcharges", server.authorized(server.serveFixture(fixtures.Charges)))
+	server.mux.HandleFunc("POST "+EloverblikPath+"meterdata/gettimeseries/{from}/{to}/{aggregation}", server.authorized(server.handleTimeSeries))
+	server.mux.HandleFunc("GET /dataset/Elspotprices", server.handleSpotPrices)
+	server.mux.HandleFunc("GET /dataset/DayAheadPrices", server.handleDayAheadPrices)
+	server.mux.HandleFunc("GET /dataset/DatahubPricelist", server.handlePricelist)
+
+	return server
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Starts a fake server on a random local port. Close it when done.
+func NewServer(fixtures *Fixtures) *httptest.Server {
+	return httptest.NewServer(NewHandler(fixtures))
+}
+
+// Returns the Eloverblik base URL of a fake server started at serverURL
+func EloverblikURL(serverURL string) string {
+	return strings.TrimSuffix(serverURL, "/") + EloverblikPath
+}
+
+// Returns the Energi Data Service base URL of a fake server started at serverURL
+func EnerginetURL(serverURL string) string {
+	return strings.TrimSuffix(serverURL, "/") + "/"
+}
+
+// authorized rejects requests without a bearer token like the real API does
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			http.Error(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// serveFixture writes a fixture as the response body
+func (s *Server) serveFixture(body []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}
+}
+
+// handleToken issues a data access token valid for 24 hours
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		http.Error(w, "missing bearer token", http.StatusUnauthorized)
+		return
+	}
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	claims := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, time.Now().Add(24*time.Hour).Unix())))
+	token := header + "." + claims + ".fake"
+
+	writeJSON(w, eloverblik.TokenResponse{Token: token})
+}
+
+// handleTimeSeries generates consumption for every Copenhagen day in the requested range.
+// The fake meter is hourly settled, so Actual and Hour give PT1H; Quarter splits every hour in four.
+func (s *Server) handleTimeSeries(w http.ResponseWriter, r *http.Request) {
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+
+	var resolution string
+	var step time.Duration
+	switch r.PathValue("aggregation") {
+	case eloverblik.AggregationActual, eloverblik.AggregationHour:
+		resolution, step = "PT1H", time.Hour
+	case eloverblik.AggregationQuarter:
+		resolution, step = "PT15M", 15*time.Minute
+	default:
+		http.Error(w, "unsupported aggregation", http.StatusBadRequest)
+		return
+	}
+	intervalsPerHour := float64(time.Hour / step)
+
+	from, err := time.ParseInLocation("2006-01-02", r.PathValue("from"), copenhagen)
+	if err != nil {
+		http.Error(w, "invalid from date", http.StatusBadRequest)
+		return
+	}
+	to, err := time.ParseInLocation("2006-01-02", r.PathValue("to"), copenhagen)
+	if err != nil {
+		http.Error(w, "invalid to date", http.StatusBadRequest)
+		return
+	}
+
+	// Eloverblik returns one period per day, starting at local midnight
+	var periods []eloverblik.Period
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		nextDay := day.AddDate(0, 0, 1)
+
+		period := eloverblik.Period{Resolution: resolution}
+		period.TimeInterval.Start = day.UTC().Format("2006-01-02T15:04:05Z")
+		period.TimeInterval.End = nextDay.UTC().Format("2006-01-02T15:04:05Z")
+
+		position := 1
+		for interval := day; interval.Before(nextDay); interval = interval.Add(step) {
+			period.Point = append(period.Point, eloverblik.Point{
+				Position: fmt.Sprintf("%d", position),
+				Quantity: fmt.Sprintf("%.4f", s.fixtures.Profiles.ConsumptionKWh[interval.In(copenhagen).Hour()]/intervalsPerHour),
+				Quality:  "A04",
+			})
+			position++
+		}
+
+		periods = append(periods, period)
+	}
+
+	var response eloverblik.ConsumptionAPIResponse
+	response.Result = []eloverblik.ResultItem{{
+		EnergyData: eloverblik.EnergyData{
+			TimeSeries: []eloverblik.TimeSeries{{
+				BusinessType:        "A04",
+				CurveType:           "A01",
+				MeasurementUnitName: "KWH",
+				Period:              periods,
+			}},
+		},
+		Success:   true,
+		ErrorCode: 10000,
+		ErrorText: "NoError",
+	}}
+
+	writeJSON(w, response)
+}
+
+// quarterHourOffsets shape the hourly profile price into four quarter-hour prices averaging to it
+var quarterHourOffsets = []float64{-0.03, -0.01, 0.01, 0.03}
+
+// parseDayRange parses the start and end query parameters as Copenhagen days
+func parseDayRange(query url.Values) (time.Time, time.Time, error) {
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+
+	start, err := time.ParseInLocation("2006-01-02", query.Get("start"), copenhagen)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid start date")
+	}
+	end, err := time.ParseInLocation("2006-01-02", query.Get("end"), copenhagen)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid end date")
+	}
+	return start, end, nil
+}
+
+// handleSpotPrices generates hourly Elspotprices records for the requested Copenhagen days.
+// Like the real dataset it has no prices from energinet.DayAheadCutover on.
+func (s *Server) handleSpotPrices(w http.ResponseWriter, r *http.Request) {
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+	query := r.URL.Query()
+
+	start, end, err := parseDayRange(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if end.After(energinet.DayAheadCutover) {
+		end = energinet.DayAheadCutover
+	}
+
+	areas, err := priceAreaFilter(query.Get("filter"), s.fixtures.Profiles.SpotPricesDKKPerMWh)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Newest first, like the sort=HourUTC DESC the client asks for
+	var records []energinet.SpotPriceRecord
+	for hour := end.Add(-time.Hour); !hour.Before(start); hour = hour.Add(-time.Hour) {
+		for _, area := range areas {
+			price := s.fixtures.Profiles.SpotPricesDKKPerMWh[area][hour.In(copenhagen).Hour()]
+			records = append(records, energinet.SpotPriceRecord{
+				HourUTC:      hour.UTC().Format("2006-01-02T15:04:05"),
+				HourDK:       hour.In(copenhagen).Format("2006-01-02T15:04:05"),
+				PriceArea:    area,
+				SpotPriceDKK: price,
+				SpotPriceEUR: price / energinet.EURToDKK,
+			})
+		}
+	}
+
+	writeJSON(w, energinet.APIResponse{
+		Total:   len(records),
+		Limit:   len(records),
+		Dataset: "Elspotprices",
+		Records: records,
+	})
+}
+
+// handleDayAheadPrices generates 15-minute DayAheadPrices records for the requested Copenhagen days,
+// from energinet.DayAheadCutover on
+func (s *Server) handleDayAheadPrices(w http.ResponseWriter, r *http.Request) {
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+	query := r.URL.Query()
+
+	start, end, err := parseDayRange(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if start.Before(energinet.DayAheadCutover) {
+		start = energinet.DayAheadCutover
+	}
+
+	areas, err := priceAreaFilter(query.Get("filter"), s.fixtures.Profiles.SpotPricesDKKPerMWh)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Newest first, like the sort=TimeUTC DESC the client asks for
+	var records []energinet.DayAheadPriceRecord
+	for quarter := end.Add(-15 * time.Minute); !quarter.Before(start); quarter = quarter.Add(-15 * time.Minute) {
+		for _, area := range areas {
+			hourPrice := s.fixtures.Profiles.SpotPricesDKKPerMWh[area][quarter.In(copenhagen).Hour()]
+			price := hourPrice * (1 + quarterHourOffsets[quarter.Minute()/15])
+			records = append(records, energinet.DayAheadPriceRecord{
+				TimeUTC:          quarter.UTC().Format("2006-01-02T15:04:05"),
+				TimeDK:           quarter.In(copenhagen).Format("2006-01-02T15:04:05"),
+				PriceArea:        area,
+				DayAheadPriceDKK: price,
+				DayAheadPriceEUR: price / energinet.EURToDKK,
+			})
+		}
+	}
+
+	writeJSON(w, energinet.DayAheadAPIResponse{
+		Total:   len(records),
+		Limit:   len(records),
+		Dataset: "DayAheadPrices",
+		Records: records,
+	})
+}
+
+// handlePricelist serves the DatahubPricelist records matching the filter and valid from before end
+func (s *Server) handlePricelist(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var filter map[string][]string
+	if query.Get("filter") != "" {
+		if err := json.Unmarshal([]byte(query.Get("filter")), &filter); err != nil {
+			http.Error(w, fmt.Sprintf("invalid filter: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// end is a timestamp prefix such as 2025-01-01T00:00, which compares correctly as a string
+	end := query.Get("end")
+
+	var records []energinet.PricelistRecord
+	for _, record := range s.fixtures.Pricelist {
+		if end != "" && record.ValidFrom >= end {
+			continue
+		}
+		if !matchesFilter(filter["GLN_Number"], record.GLNNumber) ||
+			!matchesFilter(filter["ChargeTypeCode"], record.ChargeTypeCode) ||
+			!matchesFilter(filter["Note"], record.Note) ||
+			!matchesFilter(filter["ChargeType"], record.ChargeType) {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ValidFrom > records[j].ValidFrom
+	})
+
+	writeJSON(w, energinet.PricelistAPIResponse{
+		Total:   len(records),
+		Limit:   len(records),
+		Dataset: "DatahubPricelist",
+		Records: records,
+	})
+}
+
+// matchesFilter reports whether value is one of the allowed values, or no filter is set
+func matchesFilter(allowed []string, value string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, candidate := range allowed {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// priceAreaFilter returns the price areas selected by an Energi Data Service filter parameter
+func priceAreaFilter(filter string, prices map[string][]float64) ([]string, error) {
+	if filter == "" {
+		var areas []string
+		for _, area := range []string{"DK1", "DK2"} {
+			if _, ok := prices[area]; ok {
+				areas = append(areas, area)
+			}
+		}
+		return areas, nil
+	}
+
+	var parsed struct {
+		PriceArea []string `json:"PriceArea"`
+	}
+	if err := json.Unmarshal([]byte(filter), &parsed); err != nil {
+		return nil, fmt.Errorf("invalid filter: %v", err)
+	}
+
+	for _, area := range parsed.PriceArea {
+		if _, ok := prices[area]; !ok {
+			return nil, fmt.Errorf("no fixture prices for price area %s", area)
+		}
+	}
+
+	return parsed.PriceArea, nil
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
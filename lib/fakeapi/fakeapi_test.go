@@ -0,0 +1,287 @@
+package fakeapi_test
+
+import (
+	"context"
+	"electricity-invoice-calculator/lib/billing"
+	"electricity-invoice-calculator/lib/cache"
+	"electricity-invoice-calculator/lib/eloverblik"
+	"electricity-invoice-calculator/lib/energinet"
+	"electricity-invoice-calculator/lib/fakeapi"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Total incl. VAT of the January 2025 bill of the fixture meter point, priced with the
+// fixture consumption and spot price profiles and the tariffs valid in January 2025
+const january2025TotalInclVAT = 658.5763
+
+// newClients points both API clients at the fake server, caching in store
+func newClients(serverURL string, store *cache.Store) (*eloverblik.Client, *energinet.Client) {
+	eloverblikClient := eloverblik.NewClient("fake.jwt.token", "")
+	eloverblikClient.BaseURL = fakeapi.EloverblikURL(serverURL)
+	eloverblikClient.Cache = store
+
+	energinetClient := energinet.NewClient()
+	energinetClient.BaseURL = fakeapi.EnerginetURL(serverURL)
+	energinetClient.Cache = store
+
+	return eloverblikClient, energinetClient
+}
+
+// historicalBill runs the historical bill flow of the bill command for January 2025
+func historicalBill(ctx context.Context, eloverblikClient *eloverblik.Client, energinetClient *energinet.Client) (*billing.BillSummary, error) {
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+	period := billing.Period{
+		Start:           time.Date(2025, time.January, 1, 0, 0, 0, 0, copenhagen),
+		End:             time.Date(2025, time.February, 1, 0, 0, 0, 0, copenhagen),
+		Label:           "January 2025",
+		Frequency:       billing.Monthly,
+		CalculationType: billing.Historical,
+	}
+
+	meterPoints, err := eloverblikClient.GetMeterPoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(meterPoints) != 1 {
+		return nil, errors.New("expected the one fixture meter point")
+	}
+	meterPointId := meterPoints[0].ID
+
+	if _, err = eloverblikClient.GetMeterPointDetails(ctx, meterPointId); err != nil {
+		return nil, err
+	}
+
+	consumption, err := eloverblikClient.GetConsumptionForPeriod(ctx, meterPointId, period.Start, period.End, eloverblik.AggregationHour)
+	if err != nil {
+		return nil, err
+	}
+
+	spotPrices, err := billing.FetchSpotPricesForPeriod(ctx, energinetClient, period.Start, period.End, "DK2", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	charges, err := eloverblikClient.GetCharges(ctx, meterPointId)
+	if err != nil {
+		return nil, err
+	}
+	charges, err = billing.HistoricalCharges(ctx, energinetClient, charges, period.Start, period.End)
+	if err != nil {
+		return nil, err
+	}
+
+	hourlyCosts, err := billing.CalculateAllHourlyTariffs(consumption, charges, 0.02, spotPrices)
+	if err != nil {
+		return nil, err
+	}
+
+	return billing.SummarizeBill(period, billing.PeriodHistorical, eloverblik.GetTotalConsumption(consumption), hourlyCosts, charges), nil
+}
+
+// countingServer starts the fake APIs and counts the requests they receive
+func countingServer(t *testing.T) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+
+	fixtures, err := fakeapi.DefaultFixtures()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var requests atomic.Int64
+	handler := fakeapi.NewHandler(fixtures)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func checkTotal(t *testing.T, run string, summary *billing.BillSummary) {
+	t.Helper()
+	if math.Abs(summary.TotalInclVAT-january2025TotalInclVAT) > 0.0001 {
+		t.Errorf("%s: total incl. VAT %.4f DKK, want %.4f DKK", run, summary.TotalInclVAT, january2025TotalInclVAT)
+	}
+}
+
+func TestHistoricalBill(t *testing.T) {
+	server, _ := countingServer(t)
+	eloverblikClient, energinetClient := newClients(server.URL, nil)
+
+	summary, err := historicalBill(context.Background(), eloverblikClient, energinetClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkTotal(t, "uncached", summary)
+	if summary.TotalConsumptionKWh <= 0 {
+		t.Errorf("total consumption %.2f kWh, want metered consumption", summary.TotalConsumptionKWh)
+	}
+}
+
+func TestHistoricalBillFromCache(t *testing.T) {
+	server, requests := countingServer(t)
+	store := cache.NewStore(t.TempDir())
+	ctx := context.Background()
+
+	eloverblikClient, energinetClient := newClients(server.URL, store)
+	first, err := historicalBill(ctx, eloverblikClient, energinetClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTotal(t, "first run", first)
+	firstRequests := requests.Load()
+
+	// A second run reads the time series from the cache; only snapshots are requested again
+	eloverblikClient, energinetClient = newClients(server.URL, store)
+	second, err := historicalBill(ctx, eloverblikClient, energinetClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTotal(t, "cached run", second)
+	if secondRequests := requests.Load() - firstRequests; secondRequests >= firstRequests {
+		t.Errorf("cached run made %d requests, want fewer than the %d of the first run", secondRequests, firstRequests)
+	}
+
+	// Offline, with the server gone, everything comes from the cache
+	server.Close()
+	offline := cache.NewStore(store.Dir)
+	offline.Offline = true
+	eloverblikClient, energinetClient = newClients(server.URL, offline)
+	third, err := historicalBill(ctx, eloverblikClient, energinetClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTotal(t, "offline run", third)
+}
+
+func TestOfflineMissingPeriod(t *testing.T) {
+	server, requests := countingServer(t)
+	store := cache.NewStore(t.TempDir())
+	store.Offline = true
+	_, energinetClient := newClients(server.URL, store)
+
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+	start := time.Date(2025, time.March, 1, 0, 0, 0, 0, copenhagen)
+	_, err := energinetClient.GetSpotPricesForPeriod(context.Background(), start, start.AddDate(0, 0, 2), "DK2", time.Hour)
+
+	var missing *cache.MissingError
+	if !errors.As(err, &missing) {
+		t.Fatalf("got error %v, want *cache.MissingError", err)
+	}
+	if len(missing.Hours) != 48 {
+		t.Errorf("%d hours missing, want 48", len(missing.Hours))
+	}
+	if requests.Load() != 0 {
+		t.Errorf("offline store made %d requests, want none", requests.Load())
+	}
+}
+
+func TestRecentConsumptionFetchedAgain(t *testing.T) {
+	server, requests := countingServer(t)
+	store := cache.NewStore(t.TempDir())
+	eloverblikClient, _ := newClients(server.URL, store)
+	ctx := context.Background()
+
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+	now := time.Now().In(copenhagen)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, copenhagen)
+	old := today.AddDate(0, -3, 0)
+
+	fetch := func(start time.Time) int64 {
+		t.Helper()
+		before := requests.Load()
+		if _, err := eloverblikClient.GetConsumptionForPeriod(ctx, "571313100000000001", start, start.AddDate(0, 0, 7), eloverblik.AggregationHour); err != nil {
+			t.Fatal(err)
+		}
+		return requests.Load() - before
+	}
+
+	// Days older than the revision window are final once cached
+	fetch(old)
+	if n := fetch(old); n != 0 {
+		t.Errorf("cached old week made %d requests, want none", n)
+	}
+
+	// Readings of the last weeks may still be corrected, so they are requested again
+	recent := today.AddDate(0, 0, -10)
+	fetch(recent)
+	if n := fetch(recent); n == 0 {
+		t.Error("cached recent week made no requests, want it fetched again")
+	}
+}
+
+// failingHistoryServer starts the fake APIs, failing time series requests that start before failBefore
+func failingHistoryServer(t *testing.T, failBefore time.Time) *httptest.Server {
+	t.Helper()
+
+	fixtures, err := fakeapi.DefaultFixtures()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := fakeapi.NewHandler(fixtures)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, dates, found := strings.Cut(r.URL.Path, "/gettimeseries/"); found && dates[:10] < failBefore.Format("2006-01-02") {
+			http.Error(w, "no meter data for the period", http.StatusBadRequest)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestConsumptionHistory(t *testing.T) {
+	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
+	before := time.Date(2025, time.June, 1, 0, 0, 0, 0, copenhagen)
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		since      time.Time // consumer start date
+		failBefore time.Time // time series requests starting earlier fail
+		wantFrom   time.Time // first hour of the history, zero if an error is expected
+	}{
+		{"since consumer start", time.Date(2024, time.September, 1, 0, 0, 0, 0, copenhagen), before.AddDate(-1, 0, 0), time.Date(2024, time.September, 1, 0, 0, 0, 0, copenhagen)},
+		{"older year failing", time.Time{}, before.AddDate(-1, 0, 0), before.AddDate(-1, 0, 0)},
+		{"all years", time.Time{}, time.Time{}, before.AddDate(-2, 0, 0)},
+		{"newest year failing", time.Time{}, before, time.Time{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := failingHistoryServer(t, test.failBefore)
+			eloverblikClient, _ := newClients(server.URL, nil)
+
+			history, err := billing.FetchConsumptionHistory(ctx, eloverblikClient, "571313100000000001", test.since, before)
+			if test.wantFrom.IsZero() {
+				if err == nil {
+					t.Fatal("got no error, want the failing request reported")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(history) == 0 {
+				t.Fatal("got no history")
+			}
+			if from := history[0].DateTime; !from.Equal(test.wantFrom) {
+				t.Errorf("history from %s, want %s", from.In(copenhagen), test.wantFrom)
+			}
+			if to := history[len(history)-1].DateTime; !to.Before(before) || to.Before(before.Add(-time.Hour)) {
+				t.Errorf("history to %s, want the last hour before %s", to.In(copenhagen), before)
+			}
+		})
+	}
+}
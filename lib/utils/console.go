@@ -4,6 +4,7 @@ package utils
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
@@ -22,34 +23,42 @@ var (
 	White   = "\033[97m"
 )
 
+// Writer used by the Print* functions. Set to os.Stderr to keep stdout free for data output.
+var Output io.Writer = os.Stdout
+
 // Clears the terminal screen
 func ClearConsole() {
-	fmt.Print("\033[H\033[2J")
+	fmt.Fprint(Output, "\033[H\033[2J")
 }
 
 // Prints message in white
 func PrintAction(message string) {
-	fmt.Println(White + message + Reset)
+	fmt.Fprintln(Output, White+message+Reset)
 }
 
 // Prints message in green
 func PrintSuccess(message string) {
-	fmt.Println(Green + message + Reset)
+	fmt.Fprintln(Output, Green+message+Reset)
 }
 
 // Prints message in blue
 func PrintInfo(message string) {
-	fmt.Println(Blue + message + Reset)
+	fmt.Fprintln(Output, Blue+message+Reset)
 }
 
 // Prints message in yellow
 func PrintWarning(message string) {
-	fmt.Println(Yellow + message + Reset)
+	fmt.Fprintln(Output, Yellow+message+Reset)
 }
 
 // Prints message in red
 func PrintError(message string) {
-	fmt.Println(Red + message + Reset)
+	fmt.Fprintln(Output, Red+message+Reset)
+}
+
+// Prints an empty line
+func PrintBlankLine() {
+	fmt.Fprintln(Output)
 }
 
 // Formats meter point info with appropriate colors
@@ -71,14 +80,14 @@ func GetUserChoice(title string, formattedOptions []string) int {
 
 	PrintInfo(fmt.Sprintf("\n%s\n", title))
 	for _, option := range formattedOptions {
-		fmt.Println(option)
-		fmt.Println() // Add blank line between options
+		fmt.Fprintln(Output, option)
+		fmt.Fprintln(Output) // Add blank line between options
 	}
 
 	maxChoice := len(formattedOptions)
 
 	for {
-		fmt.Printf("Please select (1-%d): ", maxChoice)
+		fmt.Fprintf(Output, "Please select (1-%d): ", maxChoice)
 		input, err := reader.ReadString('\n')
 		if err != nil {
 			PrintError("Error reading input, please try again.")
@@ -105,15 +114,15 @@ func GetUserChoice(title string, formattedOptions []string) int {
 func GetSimpleChoice(title string, options []string) int {
 	reader := bufio.NewReader(os.Stdin)
 
-	fmt.Printf("\n%s\n", title)
+	fmt.Fprintf(Output, "\n%s\n", title)
 	for i, option := range options {
-		fmt.Printf("%d) %s\n", i+1, option)
+		fmt.Fprintf(Output, "%d) %s\n", i+1, option)
 	}
 
 	maxChoice := len(options)
 
 	for {
-		fmt.Printf("Please select (1-%d): ", maxChoice)
+		fmt.Fprintf(Output, "Please select (1-%d): ", maxChoice)
 		input, err := reader.ReadString('\n')
 		if err != nil {
 			PrintError("Error reading input, please try again.")
@@ -140,7 +149,7 @@ func GetSimpleChoice(title string, options []string) int {
 func GetUserInput(prompt string) string {
 	reader := bufio.NewReader(os.Stdin)
 
-	fmt.Printf("%s: ", prompt)
+	fmt.Fprintf(Output, "%s: ", prompt)
 	input, err := reader.ReadString('\n')
 	if err != nil {
 		PrintError("Error reading input.")
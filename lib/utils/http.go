@@ -2,27 +2,120 @@ package utils
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type HTTPResponse struct {
 	Body       []byte
 	StatusCode int
+	Attempts   int // number of requests sent, including retries
 }
 
-func MakeRequestWithToken(method, url, token string, body []byte) (*HTTPResponse, error) {
+// RetryPolicy controls how requests answered with 429 or 503 are retried
+type RetryPolicy struct {
+	MaxAttempts    int           // total attempts including the first one, 1 disables retries
+	InitialBackoff time.Duration // wait before the second attempt, doubled for every further attempt
+	MaxBackoff     time.Duration // upper limit of a single wait, also applied to Retry-After
+}
+
+// Retry policy of new HTTP clients
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    4,
+	InitialBackoff: 1 * time.Second,
+	MaxBackoff:     30 * time.Second,
+}
+
+// Timeout of a single request made by new HTTP clients
+var DefaultTimeout = 60 * time.Second
+
+// HTTPClient sends API requests through an injectable *http.Client with retries
+type HTTPClient struct {
+	HTTP  *http.Client
+	Retry RetryPolicy
+}
+
+// Creates an HTTP client using DefaultTimeout and DefaultRetryPolicy
+func NewHTTPClient() *HTTPClient {
+	return &HTTPClient{
+		HTTP:  &http.Client{Timeout: DefaultTimeout},
+		Retry: DefaultRetryPolicy,
+	}
+}
+
+// HTTP client used by MakeRequest and MakeRequestWithToken
+var DefaultHTTPClient = NewHTTPClient()
+
+func MakeRequestWithToken(ctx context.Context, method, url, token string, body []byte) (*HTTPResponse, error) {
+	return DefaultHTTPClient.MakeRequestWithToken(ctx, method, url, token, body)
+}
+
+func MakeRequest(ctx context.Context, method, url string, headers map[string]string, body []byte) (*HTTPResponse, error) {
+	return DefaultHTTPClient.MakeRequest(ctx, method, url, headers, body)
+}
+
+// Sends a JSON request with a bearer token
+func (c *HTTPClient) MakeRequestWithToken(ctx context.Context, method, url, token string, body []byte) (*HTTPResponse, error) {
 	headers := make(map[string]string)
 	if token != "" {
 		headers["Authorization"] = "Bearer " + token
 	}
 	headers["Content-Type"] = "application/json"
 
-	return MakeRequest(method, url, headers, body)
+	return c.MakeRequest(ctx, method, url, headers, body)
+}
+
+// Sends a request and retries it with exponential backoff while the API answers 429 or 503.
+// Cancelling ctx aborts both a running request and a pending retry.
+func (c *HTTPClient) MakeRequest(ctx context.Context, method, url string, headers map[string]string, body []byte) (*HTTPResponse, error) {
+	policy := c.Retry
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+
+	backoff := policy.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		response, retryAfter, err := c.doRequest(ctx, method, url, headers, body)
+		if err != nil {
+			return nil, fmt.Errorf("%v (attempt %d of %d)", err, attempt, policy.MaxAttempts)
+		}
+		response.Attempts = attempt
+
+		if !isRetryableStatus(response.StatusCode) || attempt >= policy.MaxAttempts {
+			return response, nil
+		}
+
+		// Honour Retry-After when the server sends it, otherwise back off exponentially
+		wait := backoff
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		if policy.MaxBackoff > 0 && wait > policy.MaxBackoff {
+			wait = policy.MaxBackoff
+		}
+
+		PrintWarning(fmt.Sprintf("API returned status %d, retrying in %s (attempt %d of %d)...",
+			response.StatusCode, wait, attempt+1, policy.MaxAttempts))
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("gave up after attempt %d of %d: %v", attempt, policy.MaxAttempts, ctx.Err())
+		case <-timer.C:
+		}
+
+		backoff *= 2
+	}
 }
 
-func MakeRequest(method, url string, headers map[string]string, body []byte) (*HTTPResponse, error) {
+// doRequest sends a single request and returns the response and its Retry-After delay
+func (c *HTTPClient) doRequest(ctx context.Context, method, url string, headers map[string]string, body []byte) (*HTTPResponse, time.Duration, error) {
 	var bodyReader io.Reader
 	// Reads and inteperets body and gets it
 	if body != nil {
@@ -30,9 +123,9 @@ func MakeRequest(method, url string, headers map[string]string, body []byte) (*H
 	}
 
 	// Creates request
-	req, err := http.NewRequest(method, url, bodyReader)
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
 	if err != nil {
-		return nil, fmt.Errorf("could not create request: %v", err)
+		return nil, 0, fmt.Errorf("could not create request: %v", err)
 	}
 
 	// Add auth headers
@@ -40,27 +133,73 @@ func MakeRequest(method, url string, headers map[string]string, body []byte) (*H
 		req.Header.Set(key, value)
 	}
 
-	client := &http.Client{}
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %v", err)
+		return nil, 0, fmt.Errorf("HTTP request failed: %v", err)
 	}
 	defer resp.Body.Close()
 
 	responseBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("could not read response: %v", err)
+		return nil, 0, fmt.Errorf("could not read response: %v", err)
 	}
 
 	return &HTTPResponse{
 		Body:       responseBody,
 		StatusCode: resp.StatusCode,
-	}, nil
+	}, parseRetryAfter(resp.Header.Get("Retry-After")), nil
+}
+
+// isRetryableStatus reports whether a status signals a temporary overload
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+
+	return 0
+}
+
+// StatusError is returned when an API answers with an unexpected status code
+type StatusError struct {
+	StatusCode int
+	Attempts   int
+}
+
+func (e *StatusError) Error() string {
+	if e.Attempts > 1 {
+		return fmt.Sprintf("API returned status %d after %d attempts", e.StatusCode, e.Attempts)
+	}
+	return fmt.Sprintf("API returned status %d", e.StatusCode)
 }
 
 func ValidateStatusOK(response *HTTPResponse) error {
 	if response.StatusCode != http.StatusOK {
-		return fmt.Errorf("API returned status %d", response.StatusCode)
+		return &StatusError{StatusCode: response.StatusCode, Attempts: response.Attempts}
 	}
 	return nil
 }
+
+// IsUnauthorized reports whether err was caused by a 401 response
+func IsUnauthorized(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusUnauthorized
+}
@@ -1,15 +1,125 @@
 package main
 
 import (
-	"electricity-invoice-calculator/lib/billing"
+	"context"
+	"electricity-invoice-calculator/lib/cache"
 	"electricity-invoice-calculator/lib/eloverblik"
 	"electricity-invoice-calculator/lib/energinet"
 	"electricity-invoice-calculator/lib/utils"
+	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 )
 
+// command is a subcommand of the binary
+type command struct {
+	name        string
+	description string
+	run         func(ctx context.Context, args []string)
+}
+
+var commands = []command{
+	{"bill", "Calculate an electricity bill (default)", runBill},
+	{"meterpoints", "List meter points with grid operator details", runMeterPoints},
+	{"consumption", "Show metered consumption for a meter point", runConsumption},
+	{"prices", "Show spot prices for a price area", runPrices},
+	{"charges", "Show tariffs and subscriptions for a meter point", runCharges},
+	{"serve-fake", "Serve a local fake of both APIs for offline testing", runServeFake},
+}
+
+// commonOptions holds the flags shared by all subcommands
+type commonOptions struct {
+	authFile      string
+	maxAttempts   int
+	retryBackoff  time.Duration
+	httpTimeout   time.Duration
+	timeout       time.Duration
+	eloverblikURL string
+	energinetURL  string
+	cacheDir      string
+	noCache       bool
+	offline       bool
+}
+
+// addCommonFlags registers the shared flags on the flag set of a subcommand
+func addCommonFlags(flags *flag.FlagSet) *commonOptions {
+	common := &commonOptions{}
+	flags.StringVar(&common.authFile, "auth", "auth.json", "file containing the Eloverblik JWT token")
+	flags.IntVar(&common.maxAttempts, "max-attempts", utils.DefaultRetryPolicy.MaxAttempts, "maximum attempts per API request when the API answers 429 or 503")
+	flags.DurationVar(&common.retryBackoff, "retry-backoff", utils.DefaultRetryPolicy.InitialBackoff, "initial wait between attempts, doubled for every retry")
+	flags.DurationVar(&common.httpTimeout, "http-timeout", utils.DefaultTimeout, "timeout of a single API request")
+	flags.DurationVar(&common.timeout, "timeout", 0, "deadline for the whole command, e.g. 5m (0 means no deadline)")
+	flags.StringVar(&common.eloverblikURL, "eloverblik-url", eloverblik.APIEndpoint, "base URL of the Eloverblik customer API")
+	flags.StringVar(&common.energinetURL, "energidataservice-url", energinet.APIEndpoint, "base URL of the Energi Data Service API")
+	defaultCacheDir, _ := cache.DefaultDir()
+	flags.StringVar(&common.cacheDir, "cache-dir", defaultCacheDir, "directory caching consumption and spot prices between runs")
+	flags.BoolVar(&common.noCache, "no-cache", false, "always request consumption and spot prices from the APIs")
+	flags.BoolVar(&common.offline, "offline", false, "never call the APIs; use only data cached by earlier runs")
+	return common
+}
+
+// apply configures the API clients from the shared flags
+func (common *commonOptions) apply() {
+	if common.maxAttempts < 1 {
+		log.Fatalf("--max-attempts must be at least 1, got %d", common.maxAttempts)
+	}
+	if common.offline && (common.noCache || common.cacheDir == "") {
+		log.Fatal("--offline needs the cache; do not combine it with --no-cache or an empty --cache-dir")
+	}
+	utils.DefaultRetryPolicy.MaxAttempts = common.maxAttempts
+	utils.DefaultRetryPolicy.InitialBackoff = common.retryBackoff
+	utils.DefaultTimeout = common.httpTimeout
+	utils.DefaultHTTPClient = utils.NewHTTPClient()
+}
+
+// withDeadline applies the --timeout deadline to ctx
+func (common *commonOptions) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if common.timeout > 0 {
+		return context.WithTimeout(ctx, common.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// store returns the consumption and spot price cache, or nil if caching is disabled
+func (common *commonOptions) store() *cache.Store {
+	if common.noCache || common.cacheDir == "" {
+		return nil
+	}
+	store := cache.NewStore(common.cacheDir)
+	store.Offline = common.offline
+	return store
+}
+
+// newEnerginetClient creates an Energi Data Service client from the shared flags
+func (common *commonOptions) newEnerginetClient() *energinet.Client {
+	client := energinet.NewClient()
+	client.BaseURL = withTrailingSlash(common.energinetURL)
+	client.Cache = common.store()
+	return client
+}
+
+// withTrailingSlash makes sure a base URL ends in a slash
+func withTrailingSlash(baseURL string) string {
+	if strings.HasSuffix(baseURL, "/") {
+		return baseURL
+	}
+	return baseURL + "/"
+}
+
+// usage prints the available subcommands
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for the flags of a command.\n", os.Args[0])
+}
+
 // displayMeterPoint formats meter point info for user display
 func displayMeterPoint(mp eloverblik.MeterPoint, index int) string {
 	address := fmt.Sprintf("%s %s", mp.StreetName, mp.BuildingNumber)
@@ -32,369 +142,70 @@ func displayMeterPoint(mp eloverblik.MeterPoint, index int) string {
 	)
 }
 
-// authenticateUser handles the complete authentication flow
-func authenticateUser() string {
-	utils.ClearConsole()
+// authenticateUser loads the JWT token and creates the Eloverblik client.
+// The client reuses a cached data access token while it is valid.
+// Offline, no token is needed as every response comes from the cache.
+func authenticateUser(ctx context.Context, common *commonOptions) *eloverblik.Client {
+	if common.offline {
+		utils.PrintInfo("Offline: using cached Eloverblik data")
+		client := eloverblik.NewClient("", "")
+		client.Cache = common.store()
+		return client
+	}
 
 	utils.PrintAction("Getting authentication token...")
-	jwtToken, err := eloverblik.LoadAuthToken("auth.json")
+	jwtToken, err := eloverblik.LoadAuthToken(common.authFile)
 	if err != nil {
 		log.Fatal("Failed to load JWT token: ", err)
 	}
 
-	utils.PrintAction("Getting refresh token...")
-	refreshToken, err := eloverblik.GetRefreshToken(jwtToken)
-	if err != nil {
-		log.Fatal("Failed to get refresh token: ", err)
-	}
-
-	return refreshToken
-}
-
-// selectMeterPoint gets and displays meter points for user selection
-func selectMeterPoint(refreshToken string) eloverblik.MeterPoint {
-	utils.PrintAction("Getting meter points...")
-	meterPoints, err := eloverblik.GetMeterPoints(refreshToken)
+	cachePath, err := eloverblik.DefaultTokenCachePath()
 	if err != nil {
-		log.Fatal("Failed to get meter points: ", err)
+		utils.PrintWarning(fmt.Sprintf("Token cache disabled: %v", err))
 	}
 
-	if len(meterPoints) == 0 {
-		log.Fatal("No meter points found.")
-	}
-
-	utils.ClearConsole()
-
-	// Format options for display
-	title := fmt.Sprintf("Found %d meter point(s):", len(meterPoints))
-	formattedOptions := make([]string, len(meterPoints))
-	for i, mp := range meterPoints {
-		formattedOptions[i] = displayMeterPoint(mp, i)
-	}
+	client := eloverblik.NewClient(jwtToken, cachePath)
+	client.BaseURL = withTrailingSlash(common.eloverblikURL)
+	client.Cache = common.store()
 
-	selectedIndex := utils.GetUserChoice(title, formattedOptions)
-	selectedMeterPoint := meterPoints[selectedIndex]
-
-	utils.PrintSuccess(fmt.Sprintf("✓ Selected meter point: %s", selectedMeterPoint.ID))
-	return selectedMeterPoint
-}
-
-// getGridOperatorInfo fetches grid operator details for the selected meter point
-func getGridOperatorInfo(refreshToken string, meterPoint eloverblik.MeterPoint) eloverblik.MeterPointDetails {
-	utils.PrintAction("Getting detailed information...")
-	gridOperator, err := eloverblik.GetMeterPointDetails(refreshToken, meterPoint.ID)
-	if err != nil {
-		log.Fatal("Failed to get grid operator details:", err)
+	utils.PrintAction("Getting refresh token...")
+	if _, err := client.AccessToken(ctx); err != nil {
+		log.Fatal("Failed to get refresh token: ", err)
 	}
 
-	return gridOperator
-}
-
-// displayMeterPointDetails shows the selected meter point and grid operator details
-func displayMeterPointDetails(meterPoint eloverblik.MeterPoint, gridOperator eloverblik.MeterPointDetails) {
-	utils.ClearConsole()
-
-	utils.PrintInfo("Meter Point Details:")
-	fmt.Printf("ID: %s\n", meterPoint.ID)
-	fmt.Printf("Address: %s %s, %s %s\n",
-		meterPoint.StreetName,
-		meterPoint.BuildingNumber,
-		meterPoint.PostCode,
-		meterPoint.City)
-	fmt.Printf("Grid Operator: %s\n", gridOperator.Name)
-	fmt.Printf("Estimated Annual Volume: %d kWh\n", gridOperator.EstimatedAnnualVolume)
+	return client
 }
 
 func main() {
-	// Authentication
-	refreshToken := authenticateUser()
-
-	// Meter point selection
-	selectedMeterPoint := selectMeterPoint(refreshToken)
-
-	// Get grid operator info
-	gridOperator := getGridOperatorInfo(refreshToken, selectedMeterPoint)
-
-	// Display details
-	displayMeterPointDetails(selectedMeterPoint, gridOperator)
-
-	utils.ClearConsole()
-
-	// Get calculation type
-	calculationType := billing.GetCalculationType()
-	utils.PrintSuccess(fmt.Sprintf("Selected calculation type: %s", calculationType))
-
-	// Get billing frequency
-	frequency := billing.GetBillingFrequency()
-	utils.PrintSuccess(fmt.Sprintf("Selected billing frequency: %s", frequency))
-
-	// Period selection with calculation type
-	utils.PrintAction("Generating available periods...")
-
-	// Parse consumer start date
-	consumerStartDate, err := time.Parse("2006-01-02T15:04:05.000Z", selectedMeterPoint.ConsumerStartDate)
-	if err != nil {
-		log.Fatal("Failed to parse consumer start date:", err)
-	}
-
-	// Generate available periods
-	periods, err := billing.GenerateAvailablePeriods(consumerStartDate, frequency, calculationType)
-	if err != nil {
-		log.Fatal("Failed to generate periods:", err)
-	}
-
-	if len(periods) == 0 {
-		if calculationType == billing.Historical {
-			utils.PrintWarning("No complete historical periods available for calculation yet.")
-		} else {
-			utils.PrintWarning("No aconto periods available.")
-		}
+	args := os.Args[1:]
+
+	// Ctrl-C cancels running API requests. A second Ctrl-C terminates immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	// Without a subcommand (or with only flags) the bill wizard runs, as it always has
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		runBill(ctx, args)
 		return
 	}
 
-	// Let user select period
-	selectedPeriod := billing.SelectPeriod(periods)
-
-	utils.ClearConsole()
-
-	// Display selected period
-	billing.DisplaySelectedPeriod(selectedPeriod)
-
-	// NEW: Determine actual period type based on dates
-	periodType := billing.DeterminePeriodType(selectedPeriod, calculationType)
-	utils.PrintInfo(fmt.Sprintf("Detected period type: %s", periodType))
-
-	// Load grid companies mapping
-	gridMapping, err := billing.LoadGridCompaniesMapping("lib/billing/grid_companies.json")
-	if err != nil {
-		log.Fatal("Failed to load grid companies mapping:", err)
-	}
-
-	// Find price area for grid operator
-	priceArea, err := billing.FindPriceArea(gridOperator.Name, gridMapping)
-	if err != nil {
-		log.Fatal("Failed to find price area:", err)
+	if args[0] == "help" {
+		usage()
+		return
 	}
 
-	utils.PrintInfo(fmt.Sprintf("Grid operator: %s, Price area: %s", gridOperator.Name, priceArea))
-
-	// NEW: Branch based on detected period type
-	var consumptionData []eloverblik.HourlyConsumption
-	var totalConsumption float64
-	var spotPrices []energinet.SpotPriceRecord
-
-	switch periodType {
-	case billing.PeriodHistorical:
-		// Historical calculation
-		utils.PrintAction("Fetching actual consumption data...")
-		consumptionData, err = eloverblik.GetConsumptionForPeriod(
-			refreshToken,
-			selectedMeterPoint.ID,
-			selectedPeriod.Start,
-			selectedPeriod.End,
-		)
-		if err != nil {
-			log.Fatal("Failed to get consumption data:", err)
-		}
-
-		totalConsumption = eloverblik.GetTotalConsumption(consumptionData)
-		summary := eloverblik.FormatConsumptionSummary(consumptionData)
-		utils.PrintInfo(summary)
-
-		// Fetch real spot prices
-		utils.PrintAction("Fetching spot prices...")
-		spotPrices, err = billing.FetchSpotPricesForPeriod(selectedPeriod.Start, selectedPeriod.End, priceArea)
-		if err != nil {
-			log.Fatal("Failed to fetch spot prices:", err)
-		}
-		utils.PrintInfo(fmt.Sprintf("Fetched %d spot price records", len(spotPrices)))
-
-	case billing.PeriodAconto:
-		// Pure aconto calculation
-		utils.PrintAction("Estimating consumption for aconto calculation...")
-
-		// Ask about spot price method
-		spotPriceOptions := []string{
-			"Use fixed estimate (614.029 DKK/MWh)",
-			"Use historical prices from same period last year",
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			cmd.run(ctx, args[1:])
+			return
 		}
-		spotPriceChoice := utils.GetSimpleChoice("How should we estimate spot prices?", spotPriceOptions)
-		useHistoricalPrices := (spotPriceChoice == 1)
-
-		// Create aconto estimation
-		acontoEstimation, err := billing.CreateAcontoEstimation(
-			gridOperator.EstimatedAnnualVolume,
-			selectedPeriod.Start,
-			selectedPeriod.End,
-			priceArea,
-			frequency,
-			useHistoricalPrices,
-		)
-		if err != nil {
-			log.Fatal("Failed to create aconto estimation:", err)
-		}
-
-		// Display estimation summary
-		billing.DisplayAcontoEstimationSummary(acontoEstimation, selectedPeriod)
-
-		// Use estimated data
-		consumptionData = acontoEstimation.EstimatedConsumption
-		totalConsumption = acontoEstimation.TotalEstimatedkWh
-		spotPrices = acontoEstimation.EstimatedSpotPrices
-
-		utils.PrintInfo(fmt.Sprintf("Generated %d hours of estimated consumption data", len(consumptionData)))
-
-	case billing.PeriodHybrid:
-		// NEW: Hybrid calculation
-		utils.PrintAction("Performing hybrid calculation (actual + estimated data)...")
-
-		// Get fixed spot price for estimated portion
-		fixedSpotPrice := billing.GetUserFixedSpotPrice()
-
-		// Create hybrid estimation
-		hybridEstimation, err := billing.CreateHybridEstimation(
-			gridOperator.EstimatedAnnualVolume,
-			selectedPeriod,
-			frequency,
-			refreshToken,
-			selectedMeterPoint.ID,
-			priceArea,
-			fixedSpotPrice,
-		)
-		if err != nil {
-			log.Fatal("Failed to create hybrid estimation:", err)
-		}
-
-		// Display hybrid summary
-		billing.DisplayHybridEstimationSummary(hybridEstimation, selectedPeriod)
-
-		// Use combined data
-		consumptionData = hybridEstimation.CombinedConsumption
-		totalConsumption = hybridEstimation.TotalEstimatedkWh
-		spotPrices = hybridEstimation.CombinedSpotPrices
-
-		utils.PrintInfo(fmt.Sprintf("Using %d hours of combined data (%d actual + %d estimated)",
-			len(consumptionData), hybridEstimation.ActualHours, hybridEstimation.EstimatedHours))
-	}
-
-	// Continue with shared calculation logic
-	hourlyBreakdown := eloverblik.GetConsumptionByHour(consumptionData)
-	utils.PrintInfo(fmt.Sprintf("Data spread across %d different hours of day", len(hourlyBreakdown)))
-
-	utils.PrintAction("Fetching charges (tariffs and subscriptions)...")
-	chargesData, err := eloverblik.GetCharges(refreshToken, selectedMeterPoint.ID)
-	if err != nil {
-		log.Fatal("Failed to get charges data:", err)
-	}
-
-	utils.PrintSuccess("Successfully retrieved charges data")
-
-	utils.PrintAction("Calculating complete electricity bill with spot prices...")
-
-	// Set supplier price based on calculation type
-	supplierPrice := 0.0 // No supplier cost for aconto/hybrid calculations
-	if periodType == billing.PeriodHistorical {
-		supplierPrice = 0.02 // 2 øre per kWh for historical calculations only
-	}
-
-	hourlyTariffCosts := billing.CalculateAllHourlyTariffs(consumptionData, chargesData, supplierPrice, spotPrices)
-
-	// Get summaries
-	tariffSummary := billing.SummarizeTariffCosts(hourlyTariffCosts)
-	totalTariffCosts := billing.GetTotalTariffCosts(hourlyTariffCosts)
-	totalSupplierCosts := billing.GetTotalSupplierCosts(hourlyTariffCosts)
-	totalSpotCosts := billing.GetTotalSpotCosts(hourlyTariffCosts)
-
-	// Calculate subscription costs
-	var totalSubscriptionCost float64
-	subscriptionBreakdown := make(map[string]float64)
-
-	var monthsInPeriod float64
-	if frequency == billing.Monthly {
-		monthsInPeriod = 1.0
-	} else { // Quarterly
-		monthsInPeriod = 3.0
-	}
-
-	for _, subscription := range chargesData.Subscriptions {
-		monthlyCost := subscription.Price * float64(subscription.Quantity)
-		periodCost := monthlyCost * monthsInPeriod
-		subscriptionBreakdown[subscription.Name] = periodCost
-		totalSubscriptionCost += periodCost
-	}
-
-	// Calculate total bill
-	totalBill := totalTariffCosts + totalSubscriptionCost
-
-	// Calculate VAT (25% in Denmark)
-	const VAT_RATE = 0.25
-	totalVAT := totalBill * VAT_RATE
-	totalBillWithVAT := totalBill + totalVAT
-
-	// Display results with appropriate title based on period type
-	utils.ClearConsole()
-	switch periodType {
-	case billing.PeriodHistorical:
-		utils.PrintSuccess(fmt.Sprintf("=== HISTORICAL ELECTRICITY BILL FOR %s ===", selectedPeriod.Label))
-	case billing.PeriodAconto:
-		utils.PrintSuccess(fmt.Sprintf("=== ACONTO ELECTRICITY BILL ESTIMATE FOR %s ===", selectedPeriod.Label))
-	case billing.PeriodHybrid:
-		utils.PrintSuccess(fmt.Sprintf("=== HYBRID ELECTRICITY BILL FOR %s ===", selectedPeriod.Label))
-	}
-	fmt.Println()
-
-	// Consumption summary
-	copenhagen, _ := time.LoadLocation("Europe/Copenhagen")
-	utils.PrintInfo("CONSUMPTION SUMMARY:")
-	utils.PrintInfo(fmt.Sprintf("Period: %s to %s",
-		selectedPeriod.Start.In(copenhagen).Format("2006-01-02"),
-		selectedPeriod.End.AddDate(0, 0, -1).In(copenhagen).Format("2006-01-02")))
-
-	switch periodType {
-	case billing.PeriodHistorical:
-		utils.PrintInfo(fmt.Sprintf("Total consumption: %.2f kWh (actual)", totalConsumption))
-	case billing.PeriodAconto:
-		utils.PrintInfo(fmt.Sprintf("Total consumption: %.2f kWh (estimated)", totalConsumption))
-		utils.PrintInfo(fmt.Sprintf("Based on estimated annual volume: %d kWh", gridOperator.EstimatedAnnualVolume))
-	case billing.PeriodHybrid:
-		utils.PrintInfo(fmt.Sprintf("Total consumption: %.2f kWh (actual + estimated)", totalConsumption))
-		utils.PrintInfo(fmt.Sprintf("Based on estimated annual volume: %d kWh", gridOperator.EstimatedAnnualVolume))
 	}
-	fmt.Println()
 
-	// Usage-based charges
-	utils.PrintInfo("USAGE-BASED CHARGES:")
-	for name, cost := range tariffSummary {
-		utils.PrintInfo(fmt.Sprintf("%-30s: %8.2f DKK", name, cost))
-	}
-	utils.PrintInfo(fmt.Sprintf("%-30s: %8.2f DKK", "Elleverandør", totalSupplierCosts))
-	utils.PrintInfo(fmt.Sprintf("%-30s: %8.2f DKK", "Spotpris", totalSpotCosts))
-	utils.PrintInfo(fmt.Sprintf("%-30s: %8.2f DKK", "Total usage charges", totalTariffCosts))
-	fmt.Println()
-
-	// Fixed charges
-	utils.PrintInfo("FIXED MONTHLY CHARGES:")
-	for name, cost := range subscriptionBreakdown {
-		utils.PrintInfo(fmt.Sprintf("%-30s: %8.2f DKK", name, cost))
-	}
-	utils.PrintInfo(fmt.Sprintf("%-30s: %8.2f DKK", "Total subscriptions", totalSubscriptionCost))
-	fmt.Println()
-
-	// Total bill
-	utils.PrintInfo("BILL SUMMARY:")
-	utils.PrintInfo(fmt.Sprintf("%-30s: %8.2f DKK", "Subtotal (excluding VAT)", totalBill))
-	utils.PrintInfo(fmt.Sprintf("%-30s: %8.2f DKK", "VAT (25%)", totalVAT))
-	utils.PrintSuccess(fmt.Sprintf("%-30s: %8.2f DKK", "TOTAL INCLUDING VAT", totalBillWithVAT))
-	fmt.Println()
-
-	utils.PrintInfo(fmt.Sprintf("Average cost per kWh (incl. VAT): %.3f DKK", totalBillWithVAT/totalConsumption))
-
-	// Add appropriate disclaimers
-	if periodType == billing.PeriodAconto || periodType == billing.PeriodHybrid {
-		fmt.Println()
-		utils.PrintWarning("ESTIMATION DISCLAIMER:")
-		utils.PrintWarning("This includes estimates based on industry-standard monthly/quarterly division.")
-		utils.PrintWarning("Actual consumption patterns and spot prices may vary significantly.")
-		utils.PrintWarning("Use this estimate for budgeting purposes only.")
-	}
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+	usage()
+	os.Exit(2)
 }